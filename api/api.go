@@ -68,29 +68,77 @@ func Init(c conf.Config) {
 		})
 	}
 
+	// Prepare credentials
+	if err := initAuth(config.Auth); err != nil {
+		log.Fatal(err)
+	}
+	if err := initOIDC(config.OIDC); err != nil {
+		log.Fatal(err)
+	}
+	if err := initTokens(config.Delegation, config.Auth); err != nil {
+		log.Fatal(err)
+	}
+
+	// Prepare rate limits
+	if err := initRateLimit(config.RateLimit); err != nil {
+		log.Fatal(err)
+	}
+
+	// Prepare readiness probes
+	if err := initReadiness(config.Readiness); err != nil {
+		log.Fatal(err)
+	}
+
+	// Prepare the gRPC server
+	if err := initGRPC(config.GRPC); err != nil {
+		log.Fatal(err)
+	}
 }
 
 // Listen initializes HTTP handlers and listens for the requests
 func Listen() {
-	// Init handlers
+	if config.GRPC != "" {
+		go func() {
+			if err := listenGRPC(config.GRPC); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+	log.Printf("listening on %s", config.Address)
+	if err := http.ListenAndServe(config.Address, serveMux()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// serveMux returns the HTTP handlers of the API routes and the UI
+func serveMux() *http.ServeMux {
+	mux := http.NewServeMux()
 	lpp := strings.TrimRight(config.Path, "/")
-	http.HandleFunc(fmt.Sprintf("%s/", lpp), assets.IndexHandler)
-	http.HandleFunc(fmt.Sprintf("%s/search", lpp), SearchHandler)
-	http.HandleFunc(fmt.Sprintf("%s/providers", lpp), ProvidersHandler)
+
+	// API routes
+	var paths []string
+	rm := map[string][]route{}
+	for _, r := range enabledRoutes() {
+		if _, ok := rm[r.Path]; !ok {
+			paths = append(paths, r.Path)
+		}
+		rm[r.Path] = append(rm[r.Path], r)
+	}
+	for _, p := range paths {
+		mux.HandleFunc(lpp+p, methodHandler(rm[p]))
+	}
+
+	// UI
+	mux.HandleFunc(fmt.Sprintf("%s/", lpp), assets.IndexHandler)
 	if config.Path != "" {
-		http.Handle(lpp+"/public/", http.StripPrefix(lpp+"/public/", assets.PublicHandler()))
+		mux.Handle(lpp+"/public/", http.StripPrefix(lpp+"/public/", assets.PublicHandler()))
 	} else {
-		http.Handle("/public/", http.StripPrefix("/public/", assets.PublicHandler()))
+		mux.Handle("/public/", http.StripPrefix("/public/", assets.PublicHandler()))
 	}
 	if lpp != "" {
-		http.HandleFunc(fmt.Sprintf("%s", lpp), RedirectHandler)
-	}
-
-	// Listen
-	log.Printf("listening on %s", config.Address)
-	if err := http.ListenAndServe(config.Address, nil); err != nil {
-		log.Fatal(err)
+		mux.HandleFunc(fmt.Sprintf("%s", lpp), RedirectHandler)
 	}
+	return mux
 }
 
 // parseProviderList parses the provider list from a given string
@@ -135,6 +183,11 @@ func RedirectHandler(w http.ResponseWriter, req *http.Request) {
 	http.Redirect(w, req, config.Path, 301)
 }
 
+// DocsHandler redirects to the API documentation page
+func DocsHandler(w http.ResponseWriter, req *http.Request) {
+	http.Redirect(w, req, strings.TrimRight(config.Path, "/")+"/public/docs.html", 302)
+}
+
 // SearchHandler is the handler for the search route
 func SearchHandler(w http.ResponseWriter, req *http.Request) {
 
@@ -158,8 +211,22 @@ func SearchHandler(w http.ResponseWriter, req *http.Request) {
 		ResponseHandler(w, req, data)
 		return
 	}
+	if !accessProvider(req, q.Provider) {
+		w.WriteHeader(http.StatusForbidden)
+		data, _ := json.Marshal(httpError{
+			StatusCode: http.StatusForbidden,
+			Error:      http.StatusText(http.StatusForbidden),
+			Message:    "access denied to provider " + q.Provider,
+		})
+		ResponseHandler(w, req, data)
+		return
+	}
+	q.User, q.Token = delegatedToken(req, q.Provider)
 
 	if err := q.Do(); err != nil {
+		if q.HTTPStatus == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
 		w.WriteHeader(q.HTTPStatus)
 		data, _ := json.Marshal(httpError{
 			StatusCode: q.HTTPStatus,
@@ -200,11 +267,11 @@ func ProvidersHandler(w http.ResponseWriter, req *http.Request) {
 	// Prepare data
 	var data []byte
 	var err error
-	if len(providers) > 0 {
+	if pl := accessProviders(req); len(pl) > 0 {
 		if req.URL.Query().Get("output") == "pretty" {
-			data, err = json.MarshalIndent(providers, "", "  ")
+			data, err = json.MarshalIndent(pl, "", "  ")
 		} else {
-			data, err = json.Marshal(providers)
+			data, err = json.Marshal(pl)
 		}
 	}
 	if err != nil {
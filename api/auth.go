@@ -0,0 +1,168 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	conf "github.com/yieldbot/ferret/config"
+)
+
+// credential represents an API credential or an OIDC session.
+// The id is the identity of the credential which is prefixed by its kind (i.e. key:ci, oidc:jane@example.com).
+type credential struct {
+	id        string
+	name      string
+	key       string
+	username  string
+	password  string
+	providers map[string]bool
+}
+
+// credentialKey is the request context key of the authenticated credential
+type credentialKey struct{}
+
+var credentials []credential
+
+// initAuth initializes the API credentials by the given auth configuration
+func initAuth(al []conf.ListenAuth) error {
+	credentials = nil
+	for i, v := range al {
+		c := credential{
+			name:     v.Name,
+			key:      v.Key,
+			username: v.Username,
+			password: v.Password,
+		}
+		if c.key == "" && (c.username == "" || c.password == "") {
+			return fmt.Errorf("invalid auth #%d. It should have a key or a username and password", i+1)
+		}
+		if c.name == "" {
+			c.name = c.username
+		}
+		if c.name == "" {
+			c.name = fmt.Sprintf("key #%d", i+1)
+		}
+		c.id = "key:" + c.name
+
+		// Providers
+		pl, err := parseProviderList(v.Providers, false)
+		if err != nil {
+			return errors.New("invalid auth providers for " + c.name + ". Error: " + err.Error())
+		}
+		if len(pl) > 0 {
+			c.providers = make(map[string]bool)
+			for _, p := range pl {
+				c.providers[p] = true
+			}
+		}
+		credentials = append(credentials, c)
+	}
+	return nil
+}
+
+// authEnabled checks whether the API requires credentials or not
+func authEnabled() bool {
+	return len(credentials) > 0 || oidc != nil
+}
+
+// AuthHandler authenticates the requests of the given handler.
+// Credentials are accepted as an OIDC session cookie, an API key
+// (`Authorization: Bearer <key>` or `X-API-Key: <key>` headers) or by HTTP basic auth.
+// All the requests are accepted when there is no any credential.
+func AuthHandler(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !authEnabled() {
+			h(w, req)
+			return
+		}
+		r, err := authenticate(req)
+		if err != nil {
+			authChallenge(w)
+			ErrorHandler(w, req, http.StatusUnauthorized, err.Error())
+			return
+		}
+		h(w, r)
+	}
+}
+
+// authenticate returns the given request with its credential in the context
+func authenticate(req *http.Request) (*http.Request, error) {
+	if oidc != nil {
+		if s, ok := oidc.session(req); ok {
+			c := credential{id: "oidc:" + s.Email, name: s.Email, providers: oidc.access(s.Groups)}
+			return req.WithContext(context.WithValue(req.Context(), credentialKey{}, c)), nil
+		}
+	}
+
+	var key, username, password string
+	var basic bool
+	if v := req.Header.Get("X-API-Key"); v != "" {
+		key = v
+	} else if a := req.Header.Get("Authorization"); strings.HasPrefix(strings.ToLower(a), "bearer ") {
+		key = strings.TrimSpace(a[7:])
+	} else {
+		username, password, basic = req.BasicAuth()
+	}
+	if key == "" && !basic {
+		return nil, errors.New("missing credentials")
+	}
+
+	for _, c := range credentials {
+		if (key != "" && c.key != "" && secureCompare(key, c.key)) ||
+			(basic && c.username != "" && secureCompare(username, c.username) && secureCompare(password, c.password)) {
+			return req.WithContext(context.WithValue(req.Context(), credentialKey{}, c)), nil
+		}
+	}
+	return nil, errors.New("invalid credentials")
+}
+
+// authChallenge sets the authentication challenge of the unauthorized responses.
+// Browsers prompt for the basic auth credentials only if there is a user.
+func authChallenge(w http.ResponseWriter) {
+	for _, c := range credentials {
+		if c.username != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Ferret"`)
+			return
+		}
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="Ferret"`)
+}
+
+// secureCompare compares the given strings in constant time
+func secureCompare(a, b string) bool {
+	ah, bh := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ah[:], bh[:]) == 1
+}
+
+// accessProvider checks whether the credential of the given request may query
+// the given provider or not
+func accessProvider(req *http.Request, provider string) bool {
+	c, ok := req.Context().Value(credentialKey{}).(credential)
+	if !ok || c.providers == nil {
+		return true
+	}
+	return c.providers[provider]
+}
+
+// accessProviders returns the providers which the credential of the given
+// request may query
+func accessProviders(req *http.Request) []provider {
+	var pl []provider
+	for _, v := range providers {
+		if accessProvider(req, v.Name) {
+			pl = append(pl, v)
+		}
+	}
+	return pl
+}
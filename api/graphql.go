@@ -0,0 +1,494 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yieldbot/ferret/search"
+)
+
+const (
+	// graphQLMaxBody is the maximum size of the GraphQL request bodies
+	graphQLMaxBody = 1 << 20
+	// graphQLMaxSearches is the maximum number of the search fields per request.
+	// The requests are rate limited as a whole so the search fields are bounded.
+	graphQLMaxSearches = 10
+)
+
+// graphQLRequest represents a GraphQL request
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// graphQLResponse represents a GraphQL response
+type graphQLResponse struct {
+	Data   interface{}    `json:"data,omitempty"`
+	Errors []graphQLError `json:"errors,omitempty"`
+}
+
+// graphQLError represents a GraphQL error
+type graphQLError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// gqlNode represents a resolved GraphQL object
+type gqlNode struct {
+	Type   string
+	Fields map[string]interface{}
+}
+
+// gqlField represents a field of an ordered GraphQL result object
+type gqlField struct {
+	Key   string
+	Value interface{}
+}
+
+// gqlObject represents an ordered GraphQL result object
+type gqlObject []gqlField
+
+// MarshalJSON marshals the object by keeping the order of the fields
+func (o gqlObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(f.Key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// gqlExecution represents the state of a GraphQL operation execution
+type gqlExecution struct {
+	req       *http.Request
+	variables map[string]interface{}
+	fragments map[string]*gqlFragment
+	errors    []graphQLError
+}
+
+// GraphQLHandler is the handler for the GraphQL route.
+// The schema is;
+//
+//	type Query {
+//	  providers: [Provider!]!
+//	  search(provider: String!, keyword: String!, page: Int = 1, limit: Int = 10): Search
+//	}
+//	type Provider { name: String! title: String! priority: Int! }
+//	type Search { provider: String! keyword: String! page: Int! limit: Int! total: Int!
+//	  elapsed: Int! cache: String! warnings: [String!]! results: [Result!]! }
+//	type Result { link: String! title: String! description: String! date: String! from: String! }
+//
+// The search fields are resolved concurrently within the request timeout.
+func GraphQLHandler(w http.ResponseWriter, req *http.Request) {
+
+	// Prepare the request
+	var gr graphQLRequest
+	switch req.Method {
+	case http.MethodGet:
+		gr.Query = req.URL.Query().Get("query")
+		gr.OperationName = req.URL.Query().Get("operationName")
+		if v := req.URL.Query().Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &gr.Variables); err != nil {
+				graphQLErrorHandler(w, req, "invalid variables. Error: "+err.Error())
+				return
+			}
+		}
+	case http.MethodPost:
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, graphQLMaxBody))
+		if err != nil {
+			graphQLErrorHandler(w, req, "failed to read the request body. Error: "+err.Error())
+			return
+		}
+		if strings.HasPrefix(req.Header.Get("Content-Type"), "application/graphql") {
+			gr.Query = string(body)
+		} else if err := json.Unmarshal(body, &gr); err != nil {
+			graphQLErrorHandler(w, req, "failed to unmarshal JSON data. Error: "+err.Error())
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		ErrorHandler(w, req, http.StatusMethodNotAllowed, "invalid method")
+		return
+	}
+	if gr.Query == "" {
+		graphQLErrorHandler(w, req, "missing query")
+		return
+	}
+
+	// Parse the query and pick the operation
+	doc, err := parseGraphQL(gr.Query)
+	if err != nil {
+		graphQLErrorHandler(w, req, err.Error())
+		return
+	}
+	var op *gqlOperation
+	for _, v := range doc.Operations {
+		if (gr.OperationName == "" && len(doc.Operations) == 1) || v.Name == gr.OperationName {
+			op = v
+			break
+		}
+	}
+	if op == nil {
+		if gr.OperationName == "" {
+			graphQLErrorHandler(w, req, "missing operation name")
+		} else {
+			graphQLErrorHandler(w, req, fmt.Sprintf("unknown operation %q", gr.OperationName))
+		}
+		return
+	}
+	if op.Type != "query" {
+		graphQLErrorHandler(w, req, fmt.Sprintf("%s operations are not supported", op.Type))
+		return
+	}
+
+	// Prepare the variables
+	ex := gqlExecution{req: req, variables: map[string]interface{}{}, fragments: doc.Fragments}
+	for _, v := range op.Variables {
+		val, ok := gr.Variables[v.Name]
+		if !ok {
+			val = v.Default
+		}
+		if val == nil && strings.HasSuffix(v.Type, "!") {
+			graphQLErrorHandler(w, req, fmt.Sprintf("missing variable $%s", v.Name))
+			return
+		}
+		ex.variables[v.Name] = val
+	}
+
+	// Execute
+	fields := ex.collect("Query", op.SelectionSet)
+	n := 0
+	for _, f := range fields {
+		if f.Name == "search" {
+			n++
+		}
+	}
+	if n > graphQLMaxSearches {
+		graphQLErrorHandler(w, req, fmt.Sprintf("too many search fields (%d). The maximum is %d", n, graphQLMaxSearches))
+		return
+	}
+	timeout := search.ParseTimeout(req.URL.Query().Get("timeout"))
+	data := ex.root(fields, time.Now().Add(timeout))
+	DataHandler(w, req, graphQLResponse{Data: data, Errors: ex.errors})
+}
+
+// graphQLErrorHandler handles GraphQL request errors
+func graphQLErrorHandler(w http.ResponseWriter, req *http.Request, message string) {
+	w.WriteHeader(http.StatusBadRequest)
+	data, _ := json.Marshal(graphQLResponse{Errors: []graphQLError{{Message: message}}})
+	ResponseHandler(w, req, data)
+}
+
+// root resolves the root fields. Search fields are resolved concurrently and
+// the ones which don't finish by the deadline are reported as timeout.
+func (ex *gqlExecution) root(fields []*gqlSelection, deadline time.Time) gqlObject {
+	type resolved struct {
+		i   int
+		v   interface{}
+		err error
+	}
+
+	values := make([]interface{}, len(fields))
+	errs := make([]error, len(fields))
+	done := make([]bool, len(fields))
+	ch := make(chan resolved, len(fields))
+	pending := 0
+	for i, f := range fields {
+		switch f.Name {
+		case "__typename":
+			values[i], done[i] = "Query", true
+		case "providers":
+			l := []interface{}{}
+			for _, p := range accessProviders(ex.req) {
+				l = append(l, providerNode(p))
+			}
+			values[i], done[i] = l, true
+		case "search":
+			q, err := ex.searchQuery(f, deadline)
+			if err != nil {
+				errs[i], done[i] = err, true
+				continue
+			}
+			pending++
+			go func(i int, q search.Query) {
+				if err := q.Do(); err != nil {
+					ch <- resolved{i: i, err: err}
+					return
+				}
+				ch <- resolved{i: i, v: searchNode(q)}
+			}(i, q)
+		default:
+			errs[i], done[i] = fmt.Errorf("cannot query field %q on type \"Query\"", f.Name), true
+		}
+	}
+
+	// Wait for the search fields
+	timer := time.NewTimer(time.Until(deadline) + 100*time.Millisecond)
+	defer timer.Stop()
+wait:
+	for ; pending > 0; pending-- {
+		select {
+		case r := <-ch:
+			values[r.i], errs[r.i], done[r.i] = r.v, r.err, true
+		case <-timer.C:
+			break wait
+		}
+	}
+
+	// Complete the fields by the order of the selection set
+	o := gqlObject{}
+	for i, f := range fields {
+		path := []interface{}{f.Alias}
+		if !done[i] {
+			errs[i] = errors.New("timeout")
+		}
+		if errs[i] != nil {
+			ex.errors = append(ex.errors, graphQLError{Message: errs[i].Error(), Path: path})
+			o = append(o, gqlField{Key: f.Alias, Value: nil})
+			continue
+		}
+		o = append(o, gqlField{Key: f.Alias, Value: ex.complete(f, values[i], path)})
+	}
+	return o
+}
+
+// searchQuery prepares the search query of the given field
+func (ex *gqlExecution) searchQuery(f *gqlSelection, deadline time.Time) (search.Query, error) {
+	q := search.Query{Page: 1, Limit: 10, Timeout: time.Until(deadline)}
+	for k := range f.Arguments {
+		if k != "provider" && k != "keyword" && k != "page" && k != "limit" {
+			return q, fmt.Errorf("unknown argument %q on field \"search\"", k)
+		}
+	}
+
+	var err error
+	if q.Provider, err = ex.stringArg(f, "provider"); err != nil {
+		return q, err
+	}
+	if q.Keyword, err = ex.stringArg(f, "keyword"); err != nil {
+		return q, err
+	}
+	if q.Page, err = ex.intArg(f, "page", q.Page); err != nil {
+		return q, err
+	}
+	if q.Limit, err = ex.intArg(f, "limit", q.Limit); err != nil {
+		return q, err
+	}
+	if !checkProvider(q.Provider) {
+		return q, errors.New("invalid provider")
+	}
+	if !accessProvider(ex.req, q.Provider) {
+		return q, errors.New("access denied to provider " + q.Provider)
+	}
+	q.User, q.Token = delegatedToken(ex.req, q.Provider)
+	return q, nil
+}
+
+// arg returns the value of the given argument
+func (ex *gqlExecution) arg(args map[string]interface{}, name string) interface{} {
+	v := args[name]
+	if n, ok := v.(gqlVar); ok {
+		v = ex.variables[string(n)]
+	}
+	return v
+}
+
+// stringArg returns the value of the given required string argument
+func (ex *gqlExecution) stringArg(f *gqlSelection, name string) (string, error) {
+	switch v := ex.arg(f.Arguments, name).(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", fmt.Errorf("missing argument %q", name)
+	}
+	return "", fmt.Errorf("invalid argument %q. It should be a string", name)
+}
+
+// intArg returns the value of the given optional int argument
+func (ex *gqlExecution) intArg(f *gqlSelection, name string, def int) (int, error) {
+	switch v := ex.arg(f.Arguments, name).(type) {
+	case int64:
+		return int(v), nil
+	case float64:
+		// Variables are decoded from JSON as float64
+		if v == math.Trunc(v) {
+			return int(v), nil
+		}
+	case nil:
+		return def, nil
+	}
+	return 0, fmt.Errorf("invalid argument %q. It should be an int", name)
+}
+
+// collect collects the fields of the given selection set for the given type
+// by expanding the fragments, applying the directives and merging the fields
+// which have the same response key
+func (ex *gqlExecution) collect(typ string, ss []*gqlSelection) []*gqlSelection {
+	var fields []*gqlSelection
+	keys := map[string]*gqlSelection{}
+	var walk func(ss []*gqlSelection, visited map[string]bool)
+	walk = func(ss []*gqlSelection, visited map[string]bool) {
+		for _, s := range ss {
+			if !ex.included(s) {
+				continue
+			}
+			switch {
+			case s.Spread != "":
+				f, ok := ex.fragments[s.Spread]
+				if !ok {
+					ex.errors = append(ex.errors, graphQLError{Message: fmt.Sprintf("unknown fragment %q", s.Spread)})
+					continue
+				}
+				if visited[s.Spread] || f.On != typ {
+					continue
+				}
+				visited[s.Spread] = true
+				walk(f.SelectionSet, visited)
+			case s.Inline:
+				if s.On == "" || s.On == typ {
+					walk(s.SelectionSet, visited)
+				}
+			default:
+				if m, ok := keys[s.Alias]; ok {
+					m.SelectionSet = append(m.SelectionSet, s.SelectionSet...)
+					continue
+				}
+				c := *s
+				c.SelectionSet = append([]*gqlSelection(nil), s.SelectionSet...)
+				keys[s.Alias] = &c
+				fields = append(fields, &c)
+			}
+		}
+	}
+	walk(ss, map[string]bool{})
+	return fields
+}
+
+// included checks the skip and include directives of the given selection
+func (ex *gqlExecution) included(s *gqlSelection) bool {
+	if d, ok := s.Directives["skip"]; ok {
+		if v, _ := ex.arg(d, "if").(bool); v {
+			return false
+		}
+	}
+	if d, ok := s.Directives["include"]; ok {
+		if v, _ := ex.arg(d, "if").(bool); !v {
+			return false
+		}
+	}
+	return true
+}
+
+// complete completes the value of the given field by its selection set
+func (ex *gqlExecution) complete(f *gqlSelection, v interface{}, path []interface{}) interface{} {
+	switch vv := v.(type) {
+	case *gqlNode:
+		if len(f.SelectionSet) == 0 {
+			ex.errors = append(ex.errors, graphQLError{Message: fmt.Sprintf("field %q of type %q must have a selection of subfields", f.Name, vv.Type), Path: path})
+			return nil
+		}
+		o := gqlObject{}
+		for _, sf := range ex.collect(vv.Type, f.SelectionSet) {
+			p := append(append([]interface{}{}, path...), sf.Alias)
+			if sf.Name == "__typename" {
+				o = append(o, gqlField{Key: sf.Alias, Value: vv.Type})
+				continue
+			}
+			fv, ok := vv.Fields[sf.Name]
+			if !ok {
+				ex.errors = append(ex.errors, graphQLError{Message: fmt.Sprintf("cannot query field %q on type %q", sf.Name, vv.Type), Path: p})
+				o = append(o, gqlField{Key: sf.Alias, Value: nil})
+				continue
+			}
+			o = append(o, gqlField{Key: sf.Alias, Value: ex.complete(sf, fv, p)})
+		}
+		return o
+	case []interface{}:
+		if len(vv) > 0 && len(f.SelectionSet) == 0 {
+			if o, ok := vv[0].(*gqlNode); ok {
+				ex.errors = append(ex.errors, graphQLError{Message: fmt.Sprintf("field %q of type %q must have a selection of subfields", f.Name, "["+o.Type+"]"), Path: path})
+				return nil
+			}
+		}
+		l := make([]interface{}, len(vv))
+		for i, iv := range vv {
+			l[i] = ex.complete(f, iv, append(append([]interface{}{}, path...), i))
+		}
+		return l
+	}
+	if len(f.SelectionSet) > 0 {
+		ex.errors = append(ex.errors, graphQLError{Message: fmt.Sprintf("field %q must not have a selection since it is a scalar", f.Name), Path: path})
+		return nil
+	}
+	return v
+}
+
+// providerNode returns the GraphQL object of the given provider
+func providerNode(p provider) *gqlNode {
+	return &gqlNode{Type: "Provider", Fields: map[string]interface{}{
+		"name":     p.Name,
+		"title":    p.Title,
+		"priority": p.Priority,
+	}}
+}
+
+// searchNode returns the GraphQL object of the given search query
+func searchNode(q search.Query) *gqlNode {
+	results := []interface{}{}
+	for _, r := range q.Results {
+		results = append(results, &gqlNode{Type: "Result", Fields: map[string]interface{}{
+			"link":        r.Link,
+			"title":       r.Title,
+			"description": r.Description,
+			"date":        r.Date,
+			"from":        r.From,
+		}})
+	}
+	warnings := []interface{}{}
+	for _, w := range q.Warnings {
+		warnings = append(warnings, w)
+	}
+	cache := q.Cache
+	if cache == "" {
+		cache = "none"
+	}
+	return &gqlNode{Type: "Search", Fields: map[string]interface{}{
+		"provider": q.Provider,
+		"keyword":  q.Keyword,
+		"page":     q.Page,
+		"limit":    q.Limit,
+		"total":    q.Total,
+		"elapsed":  int64(q.Elapsed / time.Millisecond),
+		"cache":    cache,
+		"warnings": warnings,
+		"results":  results,
+	}}
+}
@@ -0,0 +1,480 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// gqlDocument represents a parsed GraphQL document
+type gqlDocument struct {
+	Operations []*gqlOperation
+	Fragments  map[string]*gqlFragment
+}
+
+// gqlOperation represents a GraphQL operation
+type gqlOperation struct {
+	Type         string
+	Name         string
+	Variables    []*gqlVariable
+	SelectionSet []*gqlSelection
+}
+
+// gqlVariable represents a GraphQL variable definition
+type gqlVariable struct {
+	Name    string
+	Type    string
+	Default interface{}
+}
+
+// gqlFragment represents a GraphQL fragment definition
+type gqlFragment struct {
+	Name         string
+	On           string
+	SelectionSet []*gqlSelection
+}
+
+// gqlSelection represents a field, a fragment spread or an inline fragment
+type gqlSelection struct {
+	Alias        string
+	Name         string
+	Arguments    map[string]interface{}
+	Directives   map[string]map[string]interface{}
+	SelectionSet []*gqlSelection
+	Spread       string
+	On           string
+	Inline       bool
+}
+
+// gqlVar represents a variable reference in a value
+type gqlVar string
+
+// gqlEnum represents an enum value
+type gqlEnum string
+
+// gqlParser represents a GraphQL parser
+type gqlParser struct {
+	src  string
+	pos  int
+	tok  string
+	kind int
+}
+
+// GraphQL token kinds
+const (
+	gqlEOF = iota
+	gqlPunct
+	gqlName
+	gqlInt
+	gqlFloat
+	gqlString
+)
+
+// parseGraphQL parses the given GraphQL document
+func parseGraphQL(src string) (doc *gqlDocument, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(gqlSyntaxError); ok {
+				err = e
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	p := &gqlParser{src: src}
+	p.next()
+	doc = &gqlDocument{Fragments: map[string]*gqlFragment{}}
+	for p.kind != gqlEOF {
+		switch {
+		case p.is(gqlPunct, "{"):
+			doc.Operations = append(doc.Operations, &gqlOperation{Type: "query", SelectionSet: p.selectionSet()})
+		case p.is(gqlName, "query"), p.is(gqlName, "mutation"), p.is(gqlName, "subscription"):
+			doc.Operations = append(doc.Operations, p.operation())
+		case p.is(gqlName, "fragment"):
+			f := p.fragment()
+			doc.Fragments[f.Name] = f
+		default:
+			p.fail("unexpected %q", p.tok)
+		}
+	}
+	if len(doc.Operations) == 0 {
+		return nil, errors.New("syntax error: missing operation")
+	}
+	return doc, nil
+}
+
+// gqlSyntaxError represents a GraphQL syntax error
+type gqlSyntaxError string
+
+func (e gqlSyntaxError) Error() string { return string(e) }
+
+// fail aborts the parsing with a syntax error
+func (p *gqlParser) fail(format string, args ...interface{}) {
+	panic(gqlSyntaxError(fmt.Sprintf("syntax error at %d: ", p.pos) + fmt.Sprintf(format, args...)))
+}
+
+// is checks whether the current token is the given one or not
+func (p *gqlParser) is(kind int, tok string) bool {
+	return p.kind == kind && p.tok == tok
+}
+
+// expect consumes the given punctuator
+func (p *gqlParser) expect(tok string) {
+	if !p.is(gqlPunct, tok) {
+		p.fail("expected %q, found %q", tok, p.tok)
+	}
+	p.next()
+}
+
+// name consumes a name
+func (p *gqlParser) name() string {
+	if p.kind != gqlName {
+		p.fail("expected name, found %q", p.tok)
+	}
+	n := p.tok
+	p.next()
+	return n
+}
+
+// next reads the next token
+func (p *gqlParser) next() {
+	// Skip the ignored tokens (white spaces, line terminators, commas, comments and BOM)
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+		} else if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+				p.pos++
+			}
+		} else if strings.HasPrefix(p.src[p.pos:], "\ufeff") {
+			p.pos += 3
+		} else {
+			break
+		}
+	}
+	if p.pos >= len(p.src) {
+		p.kind, p.tok = gqlEOF, "<EOF>"
+		return
+	}
+
+	s := p.pos
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.kind, p.tok = gqlPunct, "..."
+	case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+		p.pos++
+		p.kind, p.tok = gqlPunct, string(c)
+	case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		for p.pos < len(p.src) && isNameChar(p.src[p.pos]) {
+			p.pos++
+		}
+		p.kind, p.tok = gqlName, p.src[s:p.pos]
+	case c == '-' || (c >= '0' && c <= '9'):
+		p.pos++
+		p.kind = gqlInt
+		for p.pos < len(p.src) {
+			d := p.src[p.pos]
+			if d >= '0' && d <= '9' {
+				p.pos++
+			} else if d == '.' || d == 'e' || d == 'E' || ((d == '+' || d == '-') && p.kind == gqlFloat) {
+				p.kind = gqlFloat
+				p.pos++
+			} else {
+				break
+			}
+		}
+		p.tok = p.src[s:p.pos]
+	case c == '"':
+		p.kind, p.tok = gqlString, p.string()
+	default:
+		p.fail("unexpected character %q", c)
+	}
+}
+
+// string reads a string or block string token
+func (p *gqlParser) string() string {
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		e := strings.Index(p.src[p.pos+3:], `"""`)
+		if e < 0 {
+			p.fail("unterminated string")
+		}
+		v := p.src[p.pos+3 : p.pos+3+e]
+		p.pos += e + 6
+		return strings.TrimSpace(v)
+	}
+
+	var b strings.Builder
+	p.pos++
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			p.fail("unterminated string")
+		}
+		c := p.src[p.pos]
+		if c == '"' {
+			p.pos++
+			return b.String()
+		}
+		if c != '\\' {
+			r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			b.WriteRune(r)
+			p.pos += size
+			continue
+		}
+		if p.pos+1 >= len(p.src) {
+			p.fail("unterminated string")
+		}
+		switch e := p.src[p.pos+1]; e {
+		case '"', '\\', '/':
+			b.WriteByte(e)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if p.pos+6 > len(p.src) {
+				p.fail("invalid unicode escape")
+			}
+			n, err := strconv.ParseUint(p.src[p.pos+2:p.pos+6], 16, 32)
+			if err != nil {
+				p.fail("invalid unicode escape")
+			}
+			b.WriteRune(rune(n))
+			p.pos += 4
+		default:
+			p.fail("invalid escape %q", e)
+		}
+		p.pos += 2
+	}
+}
+
+// isNameChar checks whether the given character can be used in names or not
+func isNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// operation parses an operation definition
+func (p *gqlParser) operation() *gqlOperation {
+	op := &gqlOperation{Type: p.name()}
+	if p.kind == gqlName {
+		op.Name = p.name()
+	}
+	if p.is(gqlPunct, "(") {
+		p.next()
+		for !p.is(gqlPunct, ")") {
+			p.expect("$")
+			v := &gqlVariable{Name: p.name()}
+			p.expect(":")
+			v.Type = p.typeRef()
+			if p.is(gqlPunct, "=") {
+				p.next()
+				v.Default = p.value(true)
+			}
+			op.Variables = append(op.Variables, v)
+		}
+		p.next()
+	}
+	p.directives()
+	op.SelectionSet = p.selectionSet()
+	return op
+}
+
+// typeRef parses a type reference (i.e. [String!]!)
+func (p *gqlParser) typeRef() string {
+	var t string
+	if p.is(gqlPunct, "[") {
+		p.next()
+		t = "[" + p.typeRef() + "]"
+		p.expect("]")
+	} else {
+		t = p.name()
+	}
+	if p.is(gqlPunct, "!") {
+		p.next()
+		t += "!"
+	}
+	return t
+}
+
+// fragment parses a fragment definition
+func (p *gqlParser) fragment() *gqlFragment {
+	p.next()
+	f := &gqlFragment{Name: p.name()}
+	if p.name() != "on" {
+		p.fail("expected on")
+	}
+	f.On = p.name()
+	p.directives()
+	f.SelectionSet = p.selectionSet()
+	return f
+}
+
+// selectionSet parses a selection set
+func (p *gqlParser) selectionSet() []*gqlSelection {
+	p.expect("{")
+	var ss []*gqlSelection
+	for !p.is(gqlPunct, "}") {
+		if p.kind == gqlEOF {
+			p.fail("unexpected end of the document")
+		}
+		ss = append(ss, p.selection())
+	}
+	p.next()
+	if len(ss) == 0 {
+		p.fail("empty selection set")
+	}
+	return ss
+}
+
+// selection parses a field or a fragment
+func (p *gqlParser) selection() *gqlSelection {
+	if p.is(gqlPunct, "...") {
+		p.next()
+		s := &gqlSelection{}
+		if p.kind == gqlName && p.tok != "on" {
+			s.Spread = p.name()
+			s.Directives = p.directives()
+			return s
+		}
+		s.Inline = true
+		if p.is(gqlName, "on") {
+			p.next()
+			s.On = p.name()
+		}
+		s.Directives = p.directives()
+		s.SelectionSet = p.selectionSet()
+		return s
+	}
+
+	s := &gqlSelection{Name: p.name()}
+	if p.is(gqlPunct, ":") {
+		p.next()
+		s.Alias, s.Name = s.Name, p.name()
+	}
+	if s.Alias == "" {
+		s.Alias = s.Name
+	}
+	if p.is(gqlPunct, "(") {
+		s.Arguments = p.arguments()
+	}
+	s.Directives = p.directives()
+	if p.is(gqlPunct, "{") {
+		s.SelectionSet = p.selectionSet()
+	}
+	return s
+}
+
+// arguments parses an argument list
+func (p *gqlParser) arguments() map[string]interface{} {
+	p.expect("(")
+	args := map[string]interface{}{}
+	for !p.is(gqlPunct, ")") {
+		n := p.name()
+		p.expect(":")
+		args[n] = p.value(false)
+	}
+	p.next()
+	return args
+}
+
+// directives parses the directives
+func (p *gqlParser) directives() map[string]map[string]interface{} {
+	var d map[string]map[string]interface{}
+	for p.is(gqlPunct, "@") {
+		p.next()
+		if d == nil {
+			d = map[string]map[string]interface{}{}
+		}
+		n := p.name()
+		d[n] = map[string]interface{}{}
+		if p.is(gqlPunct, "(") {
+			d[n] = p.arguments()
+		}
+	}
+	return d
+}
+
+// value parses a value
+func (p *gqlParser) value(constant bool) interface{} {
+	switch p.kind {
+	case gqlPunct:
+		switch p.tok {
+		case "$":
+			if constant {
+				p.fail("unexpected variable")
+			}
+			p.next()
+			return gqlVar(p.name())
+		case "[":
+			p.next()
+			l := []interface{}{}
+			for !p.is(gqlPunct, "]") {
+				if p.kind == gqlEOF {
+					p.fail("unexpected end of the document")
+				}
+				l = append(l, p.value(constant))
+			}
+			p.next()
+			return l
+		case "{":
+			p.next()
+			o := map[string]interface{}{}
+			for !p.is(gqlPunct, "}") {
+				n := p.name()
+				p.expect(":")
+				o[n] = p.value(constant)
+			}
+			p.next()
+			return o
+		}
+	case gqlInt:
+		v, err := strconv.ParseInt(p.tok, 10, 64)
+		if err != nil {
+			p.fail("invalid int %q", p.tok)
+		}
+		p.next()
+		return v
+	case gqlFloat:
+		v, err := strconv.ParseFloat(p.tok, 64)
+		if err != nil {
+			p.fail("invalid float %q", p.tok)
+		}
+		p.next()
+		return v
+	case gqlString:
+		v := p.tok
+		p.next()
+		return v
+	case gqlName:
+		v := p.tok
+		p.next()
+		switch v {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return gqlEnum(v)
+	}
+	p.fail("unexpected %q", p.tok)
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGraphQL(t *testing.T) {
+	doc, err := parseGraphQL(`
+		# Search by a variable
+		query Find($k: String!, $limit: Int = 5, $tags: [String!]) @cached {
+			__typename
+			gh: search(provider: "github", keyword: $k, limit: $limit) {
+				...result
+				total @include(if: true)
+			}
+			search(provider: "slack", keyword: "a \"b\" ç\n", page: -2) { total }
+			... on Query { providers { name } }
+		}
+		fragment result on Search { results { link title } }
+		{ providers { name } }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Operations) != 2 || doc.Operations[1].Type != "query" || doc.Operations[1].Name != "" {
+		t.Fatalf("unexpected operations %+v", doc.Operations)
+	}
+
+	op := doc.Operations[0]
+	if op.Type != "query" || op.Name != "Find" {
+		t.Errorf("unexpected operation %+v", op)
+	}
+	vars := []gqlVariable{{Name: "k", Type: "String!"}, {Name: "limit", Type: "Int", Default: int64(5)}, {Name: "tags", Type: "[String!]"}}
+	for i, v := range op.Variables {
+		if !reflect.DeepEqual(*v, vars[i]) {
+			t.Errorf("expected variable %+v, got %+v", vars[i], *v)
+		}
+	}
+
+	ss := op.SelectionSet
+	if len(ss) != 4 {
+		t.Fatalf("unexpected selection set %+v", ss)
+	}
+	if ss[0].Name != "__typename" || ss[0].Alias != "__typename" {
+		t.Errorf("unexpected field %+v", ss[0])
+	}
+	gh := ss[1]
+	if gh.Alias != "gh" || gh.Name != "search" {
+		t.Errorf("unexpected alias %+v", gh)
+	}
+	args := map[string]interface{}{"provider": "github", "keyword": gqlVar("k"), "limit": gqlVar("limit")}
+	if !reflect.DeepEqual(gh.Arguments, args) {
+		t.Errorf("expected arguments %v, got %v", args, gh.Arguments)
+	}
+	if gh.SelectionSet[0].Spread != "result" || gh.SelectionSet[1].Directives["include"]["if"] != true {
+		t.Errorf("unexpected selection set %+v", gh.SelectionSet)
+	}
+	if v := ss[2].Arguments["keyword"]; v != "a \"b\" ç\n" {
+		t.Errorf("unexpected string %q", v)
+	}
+	if v := ss[2].Arguments["page"]; v != int64(-2) {
+		t.Errorf("unexpected int %v", v)
+	}
+	if !ss[3].Inline || ss[3].On != "Query" || ss[3].SelectionSet[0].Name != "providers" {
+		t.Errorf("unexpected inline fragment %+v", ss[3])
+	}
+
+	f, ok := doc.Fragments["result"]
+	if !ok || f.On != "Search" || f.SelectionSet[0].Name != "results" || len(f.SelectionSet[0].SelectionSet) != 2 {
+		t.Errorf("unexpected fragment %+v", f)
+	}
+}
+
+func TestParseGraphQLValues(t *testing.T) {
+	doc, err := parseGraphQL(`{ f(a: [1, 2.5, true, null, ENUM], b: {c: "d", e: """ block "x" """}) }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := map[string]interface{}{
+		"a": []interface{}{int64(1), 2.5, true, nil, gqlEnum("ENUM")},
+		"b": map[string]interface{}{"c": "d", "e": `block "x"`},
+	}
+	if got := doc.Operations[0].SelectionSet[0].Arguments; !reflect.DeepEqual(got, args) {
+		t.Errorf("expected arguments %v, got %v", args, got)
+	}
+}
+
+func TestParseGraphQLErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{``, "missing operation"},
+		{`fragment f on Query { a }`, "missing operation"},
+		{`{`, "unexpected end of the document"},
+		{`{ }`, "empty selection set"},
+		{`{ a`, "unexpected end of the document"},
+		{`{ a(b: ) }`, `unexpected ")"`},
+		{`{ a(b: "c) }`, "unterminated string"},
+		{`{ a(b: "\q") }`, "invalid escape"},
+		{`{ a(b: "\u12") }`, "invalid unicode escape"},
+		{`{ a(b: """c) }`, "unterminated string"},
+		{`{ a(b: [1, 2) }`, `unexpected ")"`},
+		{`{ a(b: [1, 2`, "unexpected end of the document"},
+		{`{ a(b: 1.2.3) }`, "invalid float"},
+		{`{ a(b: 99999999999999999999) }`, "invalid int"},
+		{`query ($a: Int = $b) { a }`, "unexpected variable"},
+		{`query ($a) { a }`, `expected ":"`},
+		{`query ($a: [Int) { a }`, `expected "]"`},
+		{`fragment f Query { a }`, "expected on"},
+		{`{ a } }`, `unexpected "}"`},
+		{`{ a ^ }`, "unexpected character"},
+		{`{ a: { b } }`, "expected name"},
+	}
+	for _, tt := range tests {
+		_, err := parseGraphQL(tt.src)
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.src)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), "syntax error") || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error %q, got %q", tt.src, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// graphQL serves the given GraphQL request
+func graphQL(t *testing.T, gr graphQLRequest) (int, map[string]interface{}) {
+	b, err := json.Marshal(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(b)))
+	req.Header.Set("Content-Type", "application/json")
+	GraphQLHandler(w, req)
+	var res map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, res
+}
+
+func TestGraphQLProviders(t *testing.T) {
+	providers = []provider{{Name: "github", Title: "Github", Priority: 100}, {Name: "slack", Title: "Slack", Priority: 200}}
+	defer func() { providers = nil }()
+
+	code, res := graphQL(t, graphQLRequest{
+		Query: `query Q($skip: Boolean!) {
+			all: providers { ...p }
+			names: providers @skip(if: $skip) { name }
+			__typename
+		}
+		query Other { providers { name } }
+		fragment p on Provider { name title }`,
+		Variables:     map[string]interface{}{"skip": true},
+		OperationName: "Q",
+	})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %v", code, res)
+	}
+	b, _ := json.Marshal(res["data"])
+	if exp := `{"__typename":"Query","all":[{"name":"github","title":"Github"},{"name":"slack","title":"Slack"}]}`; string(b) != exp {
+		t.Errorf("expected %s, got %s", exp, b)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	var many []string
+	for i := 0; i <= graphQLMaxSearches; i++ {
+		many = append(many, fmt.Sprintf(`s%d: search(provider: "github", keyword: "k") { total }`, i))
+	}
+	tests := []struct {
+		name string
+		gr   graphQLRequest
+		err  string
+	}{
+		{"syntax", graphQLRequest{Query: "{"}, "syntax error"},
+		{"missing operation", graphQLRequest{Query: "query A { __typename } query B { __typename }"}, "missing operation name"},
+		{"unknown operation", graphQLRequest{Query: "query A { __typename }", OperationName: "B"}, `unknown operation "B"`},
+		{"mutation", graphQLRequest{Query: "mutation { a }"}, "mutation operations are not supported"},
+		{"missing variable", graphQLRequest{Query: "query ($k: String!) { search(provider: \"a\", keyword: $k) { total } }"}, "missing variable $k"},
+		{"too many searches", graphQLRequest{Query: "{ " + strings.Join(many, " ") + " }"}, "too many search fields"},
+		{"too many searches by fragments", graphQLRequest{Query: "{ ...a ...b } fragment a on Query { " + strings.Join(many[:6], " ") + " } fragment b on Query { " + strings.Join(many[6:], " ") + " }"}, "too many search fields"},
+	}
+	for _, tt := range tests {
+		code, res := graphQL(t, tt.gr)
+		if code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", tt.name, code)
+			continue
+		}
+		errs, _ := res["errors"].([]interface{})
+		if len(errs) != 1 || !strings.Contains(errs[0].(map[string]interface{})["message"].(string), tt.err) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.err, res["errors"])
+		}
+	}
+}
@@ -0,0 +1,286 @@
+//go:build grpc
+// +build grpc
+
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/yieldbot/ferret/api/proto"
+	"github.com/yieldbot/ferret/search"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcServer represents the gRPC search service
+type grpcServer struct {
+	pb.UnimplementedFerretServer
+}
+
+// grpcRequestKey is the context key of the HTTP request which represents a gRPC call
+type grpcRequestKey struct{}
+
+// initGRPC checks the gRPC configuration
+func initGRPC(address string) error {
+	return nil
+}
+
+// listenGRPC listens for the gRPC requests on the given address
+func listenGRPC(address string) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	log.Printf("listening gRPC on %s", address)
+	return newGRPCServer().Serve(l)
+}
+
+// newGRPCServer returns a gRPC server with the search, health and reflection services
+func newGRPCServer() *grpc.Server {
+	s := grpc.NewServer(grpc.UnaryInterceptor(grpcUnaryInterceptor), grpc.StreamInterceptor(grpcStreamInterceptor))
+	pb.RegisterFerretServer(s, &grpcServer{})
+	hs := health.NewServer()
+	hs.SetServingStatus(pb.Ferret_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, hs)
+	reflection.Register(s)
+	return s
+}
+
+// grpcUnaryInterceptor authenticates and rate limits the unary calls of the search service
+func grpcUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := grpcAuth(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return h(ctx, req)
+}
+
+// grpcStreamInterceptor authenticates and rate limits the streaming calls of the search service
+func grpcStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+	ctx, err := grpcAuth(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return h(srv, &grpcStream{ServerStream: ss, ctx: ctx})
+}
+
+// grpcStream represents a server stream with the authenticated context
+type grpcStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context of the stream
+func (s *grpcStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcAuth authenticates and rate limits the given call like the REST API does.
+// The credentials are taken from the `authorization` or `x-api-key` metadata.
+// The health and reflection services are public.
+func grpcAuth(ctx context.Context, method string) (context.Context, error) {
+	if !strings.HasPrefix(method, "/"+pb.Ferret_ServiceDesc.ServiceName+"/") {
+		return ctx, nil
+	}
+
+	// The call is represented by an HTTP request for the credential and rate limit checks
+	req, err := http.NewRequest("POST", method, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	req = req.WithContext(ctx)
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, vl := range md {
+		if !strings.HasPrefix(k, ":") {
+			for _, v := range vl {
+				req.Header.Add(k, v)
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		req.RemoteAddr = p.Addr.String()
+	}
+
+	if authEnabled() {
+		if req, err = authenticate(req); err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+	}
+	if limiter != nil {
+		if ok, _ := limiter.allow(limiter.client(req)); !ok {
+			rateLimitedTotal.Inc("rejected")
+			return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+		}
+		rateLimitedTotal.Inc("allowed")
+	}
+	return context.WithValue(req.Context(), grpcRequestKey{}, req), nil
+}
+
+// grpcRequest returns the HTTP request of the given call context
+func grpcRequest(ctx context.Context) *http.Request {
+	if req, ok := ctx.Value(grpcRequestKey{}).(*http.Request); ok {
+		return req
+	}
+	req, _ := http.NewRequest("POST", "/", nil)
+	return req.WithContext(ctx)
+}
+
+// grpcCode returns the gRPC status code of the given HTTP status code
+func grpcCode(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Internal
+}
+
+// grpcQuery returns the search query of the given call.
+// The deadline of the call is used as the timeout if it is shorter.
+func grpcQuery(ctx context.Context, provider, keyword string, page, limit int32, timeout int64) (search.Query, error) {
+	req := grpcRequest(ctx)
+	if !checkProvider(provider) {
+		return search.Query{}, status.Error(codes.InvalidArgument, "invalid provider "+provider)
+	}
+	if !accessProvider(req, provider) {
+		return search.Query{}, status.Error(codes.PermissionDenied, "access denied to provider "+provider)
+	}
+	q := search.Query{
+		Provider: provider,
+		Keyword:  keyword,
+		Page:     search.ParsePage(strconv.Itoa(int(page))),
+		Limit:    search.ParseLimit(strconv.Itoa(int(limit))),
+		Timeout:  search.ParseTimeout(""),
+	}
+	if timeout > 0 {
+		q.Timeout = time.Duration(timeout) * time.Millisecond
+	}
+	if d, ok := ctx.Deadline(); ok && time.Until(d) < q.Timeout {
+		q.Timeout = time.Until(d)
+	}
+	q.User, q.Token = delegatedToken(req, provider)
+	return q, nil
+}
+
+// grpcResponse returns the search response of the given query
+func grpcResponse(q search.Query, elapsed time.Duration) *pb.SearchResponse {
+	r := &pb.SearchResponse{
+		Provider: q.Provider,
+		Page:     int32(q.Page),
+		Limit:    int32(q.Limit),
+		Total:    int32(q.Total),
+		Elapsed:  int64(elapsed / time.Millisecond),
+		Cache:    q.Cache,
+		Warnings: q.Warnings,
+	}
+	if r.Cache == "" {
+		r.Cache = "none"
+	}
+	for _, v := range q.Results {
+		res := &pb.Result{
+			Link:        v.Link,
+			Title:       v.Title,
+			Description: v.Description,
+			From:        v.From,
+		}
+		if !v.Date.IsZero() {
+			res.Date = timestamppb.New(v.Date)
+		}
+		r.Results = append(r.Results, res)
+	}
+	return r
+}
+
+// Search searches by the given provider
+func (s *grpcServer) Search(ctx context.Context, r *pb.SearchRequest) (*pb.SearchResponse, error) {
+	q, err := grpcQuery(ctx, r.Provider, r.Keyword, r.Page, r.Limit, r.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	if err := q.Do(); err != nil {
+		return nil, status.Error(grpcCode(q.HTTPStatus), err.Error())
+	}
+	return grpcResponse(q, time.Since(start)), nil
+}
+
+// FederatedSearch searches by the given providers concurrently and streams
+// the response of each provider as soon as it is ready.
+// The failed searches are streamed with their error.
+func (s *grpcServer) FederatedSearch(r *pb.FederatedSearchRequest, stream pb.Ferret_FederatedSearchServer) error {
+	ctx := stream.Context()
+	pl := r.Providers
+	if len(pl) == 0 {
+		for _, v := range accessProviders(grpcRequest(ctx)) {
+			pl = append(pl, v.Name)
+		}
+	}
+	var ql []search.Query
+	for _, v := range pl {
+		q, err := grpcQuery(ctx, v, r.Keyword, r.Page, r.Limit, r.Timeout)
+		if err != nil {
+			return err
+		}
+		ql = append(ql, q)
+	}
+
+	rc := make(chan *pb.SearchResponse, len(ql))
+	for _, q := range ql {
+		go func(q search.Query) {
+			start := time.Now()
+			if err := q.Do(); err != nil {
+				rc <- &pb.SearchResponse{Provider: q.Provider, Page: int32(q.Page), Limit: int32(q.Limit), Error: err.Error()}
+				return
+			}
+			rc <- grpcResponse(q, time.Since(start))
+		}(q)
+	}
+	for range ql {
+		select {
+		case res := <-rc:
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	return nil
+}
+
+// ListProviders lists the search providers which the credential of the call may query
+func (s *grpcServer) ListProviders(ctx context.Context, r *pb.ListProvidersRequest) (*pb.ListProvidersResponse, error) {
+	res := &pb.ListProvidersResponse{}
+	for _, v := range accessProviders(grpcRequest(ctx)) {
+		res.Providers = append(res.Providers, &pb.Provider{
+			Name:     v.Name,
+			Title:    v.Title,
+			Priority: v.Priority,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,26 @@
+//go:build !grpc
+// +build !grpc
+
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import "errors"
+
+// initGRPC checks the gRPC configuration.
+// The gRPC server is built only with the `grpc` build tag.
+func initGRPC(address string) error {
+	if address != "" {
+		return errors.New("failed to listen gRPC on " + address + ". Build with `-tags grpc` for the gRPC server")
+	}
+	return nil
+}
+
+// listenGRPC listens for the gRPC requests on the given address
+func listenGRPC(address string) error {
+	return initGRPC(address)
+}
@@ -0,0 +1,161 @@
+//go:build grpc
+// +build grpc
+
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"testing"
+
+	pb "github.com/yieldbot/ferret/api/proto"
+	conf "github.com/yieldbot/ferret/config"
+	"github.com/yieldbot/ferret/search"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// rpcProvider represents a search provider for the gRPC tests
+type rpcProvider struct {
+	name  string
+	title string
+	err   error
+}
+
+// Search makes a search
+func (p *rpcProvider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	args["total"] = 42
+	return []map[string]interface{}{{"Link": "https://example.com/" + args["keyword"].(string), "Title": p.title}}, nil
+}
+
+// dialGRPC starts a gRPC server on an in-memory listener and returns a client of it
+func dialGRPC(t *testing.T) *grpc.ClientConn {
+	l := bufconn.Listen(1 << 20)
+	s := newGRPCServer()
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+	cc, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return cc
+}
+
+func TestGRPC(t *testing.T) {
+	for _, p := range []*rpcProvider{{name: "test-rpc-a", title: "A"}, {name: "test-rpc-b", title: "B"}, {name: "test-rpc-fail", err: errors.New("boom")}} {
+		if err := search.ProviderRegister(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	providers = []provider{{Name: "test-rpc-a", Title: "A"}, {Name: "test-rpc-b", Title: "B"}, {Name: "test-rpc-fail"}}
+	if err := initAuth([]conf.ListenAuth{{Name: "ci", Key: "k1"}, {Name: "a", Key: "k2", Providers: "test-rpc-a"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { providers, credentials = nil, nil }()
+
+	cc := dialGRPC(t)
+	c := pb.NewFerretClient(cc)
+	ctx := context.Background()
+
+	// The health service is public
+	hr, err := healthpb.NewHealthClient(cc).Check(ctx, &healthpb.HealthCheckRequest{Service: "ferret.Ferret"})
+	if err != nil || hr.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("unexpected health %v %v", hr, err)
+	}
+
+	// The search service requires the credentials
+	if _, err := c.ListProviders(ctx, &pb.ListProvidersRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected unauthenticated, got %v", err)
+	}
+	if _, err := c.ListProviders(metadata.AppendToOutgoingContext(ctx, "x-api-key", "bad"), &pb.ListProvidersRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected unauthenticated, got %v", err)
+	}
+	actx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer k1")
+	lr, err := c.ListProviders(actx, &pb.ListProvidersRequest{})
+	if err != nil || len(lr.Providers) != 3 {
+		t.Errorf("unexpected providers %v %v", lr, err)
+	}
+
+	// Search
+	sr, err := c.Search(actx, &pb.SearchRequest{Provider: "test-rpc-a", Keyword: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sr.Page != 1 || sr.Limit != 10 || sr.Total != 42 || sr.Cache != "none" || len(sr.Results) != 1 ||
+		sr.Results[0].Link != "https://example.com/foo" || sr.Results[0].Date != nil {
+		t.Errorf("unexpected response %v", sr)
+	}
+	for _, tt := range []struct {
+		ctx  context.Context
+		req  *pb.SearchRequest
+		code codes.Code
+	}{
+		{actx, &pb.SearchRequest{Provider: "test-rpc-unknown", Keyword: "foo"}, codes.InvalidArgument},
+		{actx, &pb.SearchRequest{Provider: "test-rpc-a"}, codes.InvalidArgument},
+		{actx, &pb.SearchRequest{Provider: "test-rpc-fail", Keyword: "foo"}, codes.Internal},
+		{metadata.AppendToOutgoingContext(ctx, "x-api-key", "k2"), &pb.SearchRequest{Provider: "test-rpc-b", Keyword: "foo"}, codes.PermissionDenied},
+	} {
+		if _, err := c.Search(tt.ctx, tt.req); status.Code(err) != tt.code {
+			t.Errorf("%v: expected %s, got %v", tt.req, tt.code, err)
+		}
+	}
+
+	// The federated search streams the failed searches with their error
+	stream, err := c.FederatedSearch(actx, &pb.FederatedSearchRequest{Keyword: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for {
+		r, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r.Provider+":"+r.Error)
+	}
+	sort.Strings(got)
+	if len(got) != 3 || got[0] != "test-rpc-a:" || got[1] != "test-rpc-b:" || got[2] != "test-rpc-fail:failed to search due to boom" {
+		t.Errorf("unexpected responses %v", got)
+	}
+
+	// The federated search is limited to the accessible providers
+	stream, err = c.FederatedSearch(metadata.AppendToOutgoingContext(ctx, "x-api-key", "k2"), &pb.FederatedSearchRequest{Keyword: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for {
+		r, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r.Provider)
+	}
+	if len(got) != 1 || got[0] != "test-rpc-a" {
+		t.Errorf("unexpected responses %v", got)
+	}
+}
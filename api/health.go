@@ -0,0 +1,195 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	conf "github.com/yieldbot/ferret/config"
+	"github.com/yieldbot/ferret/metrics"
+	"github.com/yieldbot/ferret/search"
+	"golang.org/x/net/context"
+)
+
+// readiness is the readiness checker of the providers
+var readiness *readinessChecker
+
+// providerUp holds the last probe result of the providers
+var providerUp = metrics.NewGauge("ferret_provider_up", "Last readiness probe result per provider (1 for reachable).", "provider")
+
+// readinessChecker represents the readiness checker which caches the probe results
+type readinessChecker struct {
+	ttl      time.Duration
+	timeout  time.Duration
+	required map[string]bool
+	mu       sync.Mutex
+	probeMu  sync.Mutex
+	probes   map[string]probe
+}
+
+// probe represents the probe result of a provider
+type probe struct {
+	Provider string    `json:"provider"`
+	Title    string    `json:"title"`
+	Status   string    `json:"status"`
+	Required bool      `json:"required"`
+	Error    string    `json:"error,omitempty"`
+	Elapsed  int64     `json:"elapsed"`
+	Checked  time.Time `json:"checked"`
+}
+
+// initReadiness initializes the readiness checker by the given configuration
+func initReadiness(c conf.ListenReadiness) error {
+	rc := readinessChecker{
+		ttl:      30 * time.Second,
+		timeout:  3 * time.Second,
+		required: make(map[string]bool),
+		probes:   make(map[string]probe),
+	}
+	if c.TTL != "" {
+		d, err := time.ParseDuration(c.TTL)
+		if err != nil || d <= 0 {
+			return errors.New("invalid readiness ttl")
+		}
+		rc.ttl = d
+	}
+	if c.Timeout != "" {
+		d, err := time.ParseDuration(c.Timeout)
+		if err != nil || d <= 0 {
+			return errors.New("invalid readiness timeout")
+		}
+		rc.timeout = d
+	}
+	pl, err := parseProviderList(c.Required, false)
+	if err != nil {
+		return errors.New("invalid readiness required providers. Error: " + err.Error())
+	}
+	for _, v := range pl {
+		if !checkProvider(v) {
+			return errors.New("invalid readiness required provider " + v + ". It should be listened")
+		}
+		rc.required[v] = true
+	}
+	readiness = &rc
+	return nil
+}
+
+// check returns the probe results of the enabled providers.
+// The providers are probed concurrently only when their cached results are expired.
+func (rc *readinessChecker) check() []probe {
+	// Serialize the refreshes so the concurrent checks don't probe the providers twice
+	rc.probeMu.Lock()
+	defer rc.probeMu.Unlock()
+
+	now := time.Now()
+	var stale []provider
+	rc.mu.Lock()
+	for _, v := range providers {
+		if p, ok := rc.probes[v.Name]; !ok || now.Sub(p.Checked) >= rc.ttl {
+			stale = append(stale, v)
+		}
+	}
+	rc.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, v := range stale {
+		wg.Add(1)
+		go func(v provider) {
+			defer wg.Done()
+			p := rc.probe(v)
+			rc.mu.Lock()
+			rc.probes[v.Name] = p
+			rc.mu.Unlock()
+		}(v)
+	}
+	wg.Wait()
+
+	pl := []probe{}
+	rc.mu.Lock()
+	for _, v := range providers {
+		pl = append(pl, rc.probes[v.Name])
+	}
+	rc.mu.Unlock()
+	return pl
+}
+
+// probe probes the given provider
+func (rc *readinessChecker) probe(v provider) probe {
+	ctx, cancel := context.WithTimeout(context.Background(), rc.timeout)
+	defer cancel()
+
+	p := probe{Provider: v.Name, Title: v.Title, Status: "ok", Required: rc.required[v.Name], Checked: time.Now()}
+	err := search.Probe(ctx, v.Name)
+	p.Elapsed = int64(time.Since(p.Checked) / time.Millisecond)
+	switch {
+	case err == search.ErrProbeUnsupported:
+		p.Status = "unsupported"
+		return p
+	case err == context.DeadlineExceeded:
+		p.Status = "error"
+		p.Error = "timeout"
+	case err != nil:
+		p.Status = "error"
+		p.Error = err.Error()
+	}
+	if err != nil {
+		providerUp.Set(0, v.Name)
+	} else {
+		providerUp.Set(1, v.Name)
+	}
+	return p
+}
+
+// HealthzHandler is the handler for the liveness route. It reports the process is alive.
+func HealthzHandler(w http.ResponseWriter, req *http.Request) {
+	DataHandler(w, req, map[string]string{"status": "ok"})
+}
+
+// status returns the readiness status of the given probe results.
+// It's unready only when a required provider is unreachable.
+func (rc *readinessChecker) status(pl []probe) string {
+	for _, v := range pl {
+		if v.Required && v.Status == "error" {
+			return "unready"
+		}
+	}
+	return "ready"
+}
+
+// ReadyzHandler is the handler for the readiness route.
+// It reports only the readiness status and returns 503 when any of the required
+// providers is unreachable. The other providers don't affect the readiness.
+func ReadyzHandler(w http.ResponseWriter, req *http.Request) {
+	status := readiness.status(readiness.check())
+	if status != "ready" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	DataHandler(w, req, map[string]string{"status": status})
+}
+
+// ReadinessV2Handler is the handler for the v2 readiness route.
+// It reports the probe results of the providers which the credential may query.
+func ReadinessV2Handler(w http.ResponseWriter, req *http.Request) {
+	pl := []probe{}
+	for _, v := range readiness.check() {
+		if accessProvider(req, v.Provider) {
+			pl = append(pl, v)
+		}
+	}
+	DataHandler(w, req, envelope{
+		Pagination: &envelopePagination{
+			Page:  1,
+			Limit: len(pl),
+			Total: len(pl),
+		},
+		Warnings: []string{},
+		Results:  pl,
+	})
+}
@@ -0,0 +1,101 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	conf "github.com/yieldbot/ferret/config"
+	"github.com/yieldbot/ferret/search"
+	"golang.org/x/net/context"
+)
+
+// probedProvider represents a probed provider for the tests
+type probedProvider struct {
+	name  string
+	err   error
+	count int
+}
+
+// Search makes a search
+func (p *probedProvider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+	return nil, nil
+}
+
+// Probe probes the provider
+func (p *probedProvider) Probe(ctx context.Context) error {
+	p.count++
+	return p.err
+}
+
+func TestReadiness(t *testing.T) {
+	up := &probedProvider{name: "test-up"}
+	down := &probedProvider{name: "test-down", err: errors.New("dial tcp: connection refused")}
+	for _, p := range []*probedProvider{up, down} {
+		if err := search.ProviderRegister(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	providers = []provider{{Name: "test-up"}, {Name: "test-down"}}
+	defer func() { providers, readiness = nil, nil }()
+
+	for _, c := range []conf.ListenReadiness{{TTL: "0s"}, {TTL: "-1s"}, {Timeout: "0s"}, {Required: "test-unknown"}} {
+		if err := initReadiness(c); err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+
+	// The unreachable providers don't affect the readiness unless they are required
+	for _, tt := range []struct {
+		required string
+		code     int
+		status   string
+	}{
+		{"", http.StatusOK, "ready"},
+		{"test-up", http.StatusOK, "ready"},
+		{"test-up,test-down", http.StatusServiceUnavailable, "unready"},
+	} {
+		if err := initReadiness(conf.ListenReadiness{Required: tt.required}); err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		ReadyzHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if w.Code != tt.code {
+			t.Errorf("%q: expected %d, got %d", tt.required, tt.code, w.Code)
+		}
+		// The public response doesn't leak the probe errors
+		if exp := `{"status":"` + tt.status + `"}`; strings.TrimSpace(w.Body.String()) != exp {
+			t.Errorf("%q: expected %s, got %s", tt.required, exp, w.Body.String())
+		}
+	}
+
+	// The probe results are cached
+	n := up.count
+	ReadyzHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if up.count != n {
+		t.Errorf("expected the cached probe result, got %d probes", up.count-n)
+	}
+
+	// The details are reported by the v2 route
+	w := httptest.NewRecorder()
+	ReadinessV2Handler(w, httptest.NewRequest(http.MethodGet, "/v2/readiness", nil))
+	var e struct {
+		Results []probe `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Results) != 2 || e.Results[0].Status != "ok" || e.Results[1].Status != "error" || !e.Results[1].Required ||
+		e.Results[1].Error != "dial tcp: connection refused" {
+		t.Errorf("unexpected probe results %+v", e.Results)
+	}
+}
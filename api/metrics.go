@@ -0,0 +1,64 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yieldbot/ferret/metrics"
+)
+
+var (
+	requestTotal     = metrics.NewCounter("ferret_http_requests_total", "HTTP requests per API route and status.", "route", "status")
+	requestDuration  = metrics.NewHistogram("ferret_http_request_duration_seconds", "HTTP request latency per API route.", nil, "route")
+	rateLimitedTotal = metrics.NewCounter("ferret_ratelimit_requests_total", "Rate limited requests per result (allowed or rejected).", "result")
+)
+
+// statusWriter represents a response writer which records the status code
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code and writes the header
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes the data
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// MetricsHandler records the request count and latency of the given route
+func MetricsHandler(path string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		h(sw, req)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		requestDuration.Observe(time.Since(start).Seconds(), path)
+		requestTotal.Inc(path, strconv.Itoa(sw.status))
+	}
+}
+
+// PrometheusHandler is the handler for the metrics route.
+// It writes the metrics in the Prometheus text format.
+func PrometheusHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.Write(w)
+}
@@ -0,0 +1,50 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	handlers := map[string]http.HandlerFunc{
+		"/test/empty": func(w http.ResponseWriter, req *http.Request) {},
+		"/test/body":  func(w http.ResponseWriter, req *http.Request) { w.Write([]byte("ok")) },
+		"/test/error": func(w http.ResponseWriter, req *http.Request) {
+			ErrorHandler(w, req, http.StatusNotFound, "not found")
+		},
+		"/test/twice": func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+	}
+	for p, h := range handlers {
+		for i := 0; i < 2; i++ {
+			MetricsHandler(p, h)(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+		}
+	}
+
+	w := httptest.NewRecorder()
+	PrometheusHandler(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %s", ct)
+	}
+	for _, l := range []string{
+		`ferret_http_requests_total{route="/test/empty",status="200"} 2`,
+		`ferret_http_requests_total{route="/test/body",status="200"} 2`,
+		`ferret_http_requests_total{route="/test/error",status="404"} 2`,
+		`ferret_http_requests_total{route="/test/twice",status="202"} 2`,
+		`ferret_http_request_duration_seconds_count{route="/test/error"} 2`,
+	} {
+		if !strings.Contains(w.Body.String(), l+"\n") {
+			t.Errorf("missing %s", l)
+		}
+	}
+}
@@ -0,0 +1,697 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	// Hash functions of the ID token signatures
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	conf "github.com/yieldbot/ferret/config"
+)
+
+const (
+	sessionCookie = "ferret_session"
+	stateCookie   = "ferret_oidc"
+	stateTTL      = 10 * time.Minute
+)
+
+// oidc is the OpenID Connect client. It's nil when OIDC is not configured.
+var oidc *oidcClient
+
+// oidcClient represents an OpenID Connect client
+type oidcClient struct {
+	config    conf.ListenOIDC
+	scopes    []string
+	secret    []byte
+	ttl       time.Duration
+	providers map[string][]string
+	client    *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]crypto.PublicKey
+}
+
+// oidcDiscovery represents the OpenID Connect discovery document
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+}
+
+// oidcState represents the state of an authorization request
+type oidcState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect"`
+	Expires  int64  `json:"exp"`
+}
+
+// session represents a user session
+type session struct {
+	Email   string   `json:"email"`
+	Name    string   `json:"name"`
+	Groups  []string `json:"groups"`
+	Expires int64    `json:"exp"`
+}
+
+// initOIDC initializes the OpenID Connect client by the given configuration
+func initOIDC(c conf.ListenOIDC) error {
+	oidc = nil
+	if c.Issuer == "" {
+		return nil
+	}
+	if c.ClientID == "" {
+		return errors.New("missing oidc client id")
+	}
+
+	o := oidcClient{
+		config:    c,
+		scopes:    c.Scopes,
+		ttl:       12 * time.Hour,
+		providers: make(map[string][]string),
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+	if len(o.scopes) == 0 {
+		o.scopes = []string{"openid", "email", "profile"}
+	}
+	if o.config.GroupsClaim == "" {
+		o.config.GroupsClaim = "groups"
+	}
+	if c.SessionTTL != "" {
+		d, err := time.ParseDuration(c.SessionTTL)
+		if err != nil {
+			return errors.New("invalid oidc session ttl. Error: " + err.Error())
+		}
+		o.ttl = d
+	}
+
+	// The sessions don't survive restarts without a configured secret
+	if c.SessionSecret != "" {
+		s := sha256.Sum256([]byte(c.SessionSecret))
+		o.secret = s[:]
+	} else {
+		o.secret = make([]byte, 32)
+		if _, err := rand.Read(o.secret); err != nil {
+			return err
+		}
+	}
+
+	for g, v := range c.GroupProviders {
+		pl, err := parseProviderList(v, false)
+		if err != nil {
+			return errors.New("invalid oidc group providers for " + g + ". Error: " + err.Error())
+		}
+		o.providers[g] = pl
+	}
+
+	oidc = &o
+	return nil
+}
+
+// LoginHandler is the handler for the login route.
+// It redirects to the identity provider by an authorization code request.
+func LoginHandler(w http.ResponseWriter, req *http.Request) {
+	if oidc == nil {
+		ErrorHandler(w, req, http.StatusNotFound, "oidc is not configured")
+		return
+	}
+	d, err := oidc.discover(req.Context())
+	if err != nil {
+		ErrorHandler(w, req, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	st := oidcState{
+		State:    randomString(),
+		Nonce:    randomString(),
+		Verifier: randomString(),
+		Redirect: req.URL.Query().Get("redirect"),
+		Expires:  time.Now().Add(stateTTL).Unix(),
+	}
+	// Only local redirects are allowed
+	if !strings.HasPrefix(st.Redirect, "/") || strings.HasPrefix(st.Redirect, "//") || strings.HasPrefix(st.Redirect, "/\\") {
+		st.Redirect = strings.TrimRight(config.Path, "/") + "/"
+	}
+	v, err := oidc.sign(st)
+	if err != nil {
+		ErrorHandler(w, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+	setCookie(w, req, stateCookie, v, stateTTL)
+
+	cc := sha256.Sum256([]byte(st.Verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {oidc.config.ClientID},
+		"redirect_uri":          {oidc.redirectURL(req)},
+		"scope":                 {strings.Join(oidc.scopes, " ")},
+		"state":                 {st.State},
+		"nonce":                 {st.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(cc[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, req, d.AuthorizationEndpoint+sep+q.Encode(), http.StatusFound)
+}
+
+// CallbackHandler is the handler for the authorization callback route.
+// It exchanges the authorization code, verifies the ID token and starts the session.
+func CallbackHandler(w http.ResponseWriter, req *http.Request) {
+	if oidc == nil {
+		ErrorHandler(w, req, http.StatusNotFound, "oidc is not configured")
+		return
+	}
+	if e := req.URL.Query().Get("error"); e != "" {
+		if ed := req.URL.Query().Get("error_description"); ed != "" {
+			e += ": " + ed
+		}
+		ErrorHandler(w, req, http.StatusUnauthorized, e)
+		return
+	}
+
+	// Check the state
+	var st oidcState
+	c, err := req.Cookie(stateCookie)
+	if err != nil || oidc.verify(c.Value, &st) != nil || st.Expires < time.Now().Unix() {
+		ErrorHandler(w, req, http.StatusBadRequest, "invalid or expired login state")
+		return
+	}
+	if st.State == "" || !hmac.Equal([]byte(st.State), []byte(req.URL.Query().Get("state"))) {
+		ErrorHandler(w, req, http.StatusBadRequest, "invalid state")
+		return
+	}
+	setCookie(w, req, stateCookie, "", -1)
+
+	// Exchange the code and verify the identity
+	claims, err := oidc.exchange(req.Context(), req.URL.Query().Get("code"), st, oidc.redirectURL(req))
+	if err != nil {
+		ErrorHandler(w, req, http.StatusUnauthorized, err.Error())
+		return
+	}
+	s := session{Expires: time.Now().Add(oidc.ttl).Unix()}
+	s.Email, _ = claims["email"].(string)
+	s.Name, _ = claims["name"].(string)
+	switch v := claims[oidc.config.GroupsClaim].(type) {
+	case []interface{}:
+		for _, g := range v {
+			if gs, ok := g.(string); ok {
+				s.Groups = append(s.Groups, gs)
+			}
+		}
+	case string:
+		s.Groups = []string{v}
+	}
+	if s.Email == "" {
+		s.Email, _ = claims["sub"].(string)
+	}
+	if err := oidc.authorize(s, claims); err != nil {
+		ErrorHandler(w, req, http.StatusForbidden, err.Error())
+		return
+	}
+
+	v, err := oidc.sign(s)
+	if err != nil {
+		ErrorHandler(w, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+	setCookie(w, req, sessionCookie, v, oidc.ttl)
+	http.Redirect(w, req, st.Redirect, http.StatusFound)
+}
+
+// LogoutHandler is the handler for the logout route.
+// It ends the session and the identity provider session when it's supported.
+func LogoutHandler(w http.ResponseWriter, req *http.Request) {
+	setCookie(w, req, sessionCookie, "", -1)
+	home := strings.TrimRight(config.Path, "/") + "/"
+	if oidc != nil {
+		if d, err := oidc.discover(req.Context()); err == nil && d.EndSessionEndpoint != "" {
+			q := url.Values{
+				"client_id":                {oidc.config.ClientID},
+				"post_logout_redirect_uri": {requestScheme(req) + "://" + req.Host + home},
+			}
+			sep := "?"
+			if strings.Contains(d.EndSessionEndpoint, "?") {
+				sep = "&"
+			}
+			http.Redirect(w, req, d.EndSessionEndpoint+sep+q.Encode(), http.StatusFound)
+			return
+		}
+	}
+	http.Redirect(w, req, home, http.StatusFound)
+}
+
+// session returns the valid session of the given request
+func (o *oidcClient) session(req *http.Request) (session, bool) {
+	var s session
+	c, err := req.Cookie(sessionCookie)
+	if err != nil || o.verify(c.Value, &s) != nil || s.Expires < time.Now().Unix() {
+		return s, false
+	}
+	return s, true
+}
+
+// access returns the providers which the given groups may query.
+// All the providers are allowed when there is no group provider mapping.
+func (o *oidcClient) access(groups []string) map[string]bool {
+	if len(o.providers) == 0 {
+		return nil
+	}
+	pm := make(map[string]bool)
+	for _, g := range groups {
+		for _, p := range o.providers[g] {
+			pm[p] = true
+		}
+	}
+	return pm
+}
+
+// authorize checks the allowed email domains and groups for the given session.
+// The email domains are checked only for the verified emails unless the
+// unverified emails are allowed explicitly.
+func (o *oidcClient) authorize(s session, claims map[string]interface{}) error {
+	if len(o.config.Domains) > 0 {
+		if v, _ := claims["email_verified"].(bool); !v && !o.config.UnverifiedEmails {
+			return errors.New("access denied to " + s.Email + ". The email is not verified")
+		}
+		ok := false
+		if i := strings.LastIndex(s.Email, "@"); i >= 0 {
+			for _, d := range o.config.Domains {
+				if strings.EqualFold(s.Email[i+1:], d) {
+					ok = true
+					break
+				}
+			}
+		}
+		if !ok {
+			return errors.New("access denied to " + s.Email + ". The email domain is not allowed")
+		}
+	}
+	if len(o.config.Groups) > 0 {
+		for _, g := range s.Groups {
+			for _, ag := range o.config.Groups {
+				if g == ag {
+					return nil
+				}
+			}
+		}
+		return errors.New("access denied to " + s.Email + ". The groups are not allowed")
+	}
+	return nil
+}
+
+// redirectURL returns the redirect URL of the authorization requests
+func (o *oidcClient) redirectURL(req *http.Request) string {
+	if o.config.RedirectURL != "" {
+		return o.config.RedirectURL
+	}
+	return requestScheme(req) + "://" + req.Host + strings.TrimRight(config.Path, "/") + "/callback"
+}
+
+// discover returns the discovery document of the issuer
+func (o *oidcClient) discover(ctx context.Context) (*oidcDiscovery, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.discovery != nil {
+		return o.discovery, nil
+	}
+
+	var d oidcDiscovery
+	if err := o.getJSON(ctx, strings.TrimRight(o.config.Issuer, "/")+"/.well-known/openid-configuration", "", &d); err != nil {
+		return nil, errors.New("failed to discover the oidc issuer. Error: " + err.Error())
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("invalid oidc discovery document")
+	}
+	if d.Issuer == "" {
+		d.Issuer = o.config.Issuer
+	}
+	o.discovery = &d
+	return o.discovery, nil
+}
+
+// exchange exchanges the given authorization code and returns the identity claims
+func (o *oidcClient) exchange(ctx context.Context, code string, st oidcState, redirectURL string) (map[string]interface{}, error) {
+	d, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"client_id":     {o.config.ClientID},
+		"code_verifier": {st.Verifier},
+	}
+	req, err := http.NewRequest("POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.New("failed to prepare request. Error: " + err.Error())
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if o.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(o.config.ClientID), url.QueryEscape(o.config.ClientSecret))
+	}
+	res, err := o.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var tr struct {
+		IDToken          string `json:"id_token"`
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&tr); err != nil {
+		return nil, errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+	}
+	if tr.Error != "" {
+		return nil, fmt.Errorf("failed to exchange the code. Error: %s %s", tr.Error, tr.ErrorDescription)
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("bad response: %d", res.StatusCode)
+	}
+
+	claims, err := o.verifyIDToken(ctx, tr.IDToken, st.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill the missing claims by the userinfo
+	if _, ok := claims[o.config.GroupsClaim]; (!ok || claims["email"] == nil) && d.UserinfoEndpoint != "" && tr.AccessToken != "" {
+		var ui map[string]interface{}
+		if err := o.getJSON(ctx, d.UserinfoEndpoint, tr.AccessToken, &ui); err == nil && ui["sub"] == claims["sub"] {
+			for k, v := range ui {
+				if _, ok := claims[k]; !ok {
+					claims[k] = v
+				}
+			}
+		}
+	}
+	return claims, nil
+}
+
+// verifyIDToken verifies the given ID token and returns its claims
+func (o *oidcClient) verifyIDToken(ctx context.Context, token, nonce string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("invalid id token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errors.New("invalid id token header")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("invalid id token signature")
+	}
+
+	// Signature
+	if len(header.Alg) != 5 {
+		return nil, errors.New("unsupported id token algorithm " + header.Alg)
+	}
+	var h crypto.Hash
+	switch header.Alg[2:] {
+	case "256":
+		h = crypto.SHA256
+	case "384":
+		h = crypto.SHA384
+	case "512":
+		h = crypto.SHA512
+	default:
+		return nil, errors.New("unsupported id token algorithm " + header.Alg)
+	}
+	hh := h.New()
+	hh.Write([]byte(parts[0] + "." + parts[1]))
+	digest := hh.Sum(nil)
+	switch header.Alg[:2] {
+	case "HS":
+		m := hmac.New(h.New, []byte(o.config.ClientSecret))
+		m.Write([]byte(parts[0] + "." + parts[1]))
+		if o.config.ClientSecret == "" || !hmac.Equal(m.Sum(nil), sig) {
+			return nil, errors.New("invalid id token signature")
+		}
+	case "RS", "ES":
+		key, err := o.key(ctx, header.Kid)
+		if err != nil {
+			return nil, err
+		}
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			if header.Alg[:2] != "RS" || rsa.VerifyPKCS1v15(k, h, digest, sig) != nil {
+				return nil, errors.New("invalid id token signature")
+			}
+		case *ecdsa.PublicKey:
+			n := len(sig) / 2
+			if header.Alg[:2] != "ES" || n == 0 || !ecdsa.Verify(k, digest, new(big.Int).SetBytes(sig[:n]), new(big.Int).SetBytes(sig[n:])) {
+				return nil, errors.New("invalid id token signature")
+			}
+		default:
+			return nil, errors.New("invalid id token key")
+		}
+	default:
+		return nil, errors.New("unsupported id token algorithm " + header.Alg)
+	}
+
+	// Claims
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, errors.New("invalid id token claims")
+	}
+	if iss, _ := claims["iss"].(string); iss != o.discovery.Issuer {
+		return nil, errors.New("invalid id token issuer")
+	}
+	aud := false
+	switch v := claims["aud"].(type) {
+	case string:
+		aud = v == o.config.ClientID
+	case []interface{}:
+		for _, a := range v {
+			if a == o.config.ClientID {
+				aud = true
+			}
+		}
+	}
+	if !aud {
+		return nil, errors.New("invalid id token audience")
+	}
+	// Allow a minute of clock skew
+	if exp, _ := claims["exp"].(float64); int64(exp) < time.Now().Add(-time.Minute).Unix() {
+		return nil, errors.New("expired id token")
+	}
+	if n, _ := claims["nonce"].(string); !hmac.Equal([]byte(n), []byte(nonce)) {
+		return nil, errors.New("invalid id token nonce")
+	}
+	return claims, nil
+}
+
+// key returns the public key of the given key id. The keys are fetched again
+// when the key id is unknown since the issuer may rotate the keys.
+func (o *oidcClient) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	o.mu.Lock()
+	k, ok := o.keys[kid]
+	if !ok && kid == "" && len(o.keys) == 1 {
+		for _, v := range o.keys {
+			k, ok = v, true
+		}
+	}
+	jwksURI := o.discovery.JWKSURI
+	o.mu.Unlock()
+	if ok {
+		return k, nil
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := o.getJSON(ctx, jwksURI, "", &jwks); err != nil {
+		return nil, errors.New("failed to fetch the oidc keys. Error: " + err.Error())
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, v := range jwks.Keys {
+		if v.Use != "" && v.Use != "sig" {
+			continue
+		}
+		switch v.Kty {
+		case "RSA":
+			n, err1 := base64.RawURLEncoding.DecodeString(v.N)
+			e, err2 := base64.RawURLEncoding.DecodeString(v.E)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			keys[v.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var c elliptic.Curve
+			switch v.Crv {
+			case "P-256":
+				c = elliptic.P256()
+			case "P-384":
+				c = elliptic.P384()
+			case "P-521":
+				c = elliptic.P521()
+			default:
+				continue
+			}
+			x, err1 := base64.RawURLEncoding.DecodeString(v.X)
+			y, err2 := base64.RawURLEncoding.DecodeString(v.Y)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			keys[v.Kid] = &ecdsa.PublicKey{Curve: c, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+
+	o.mu.Lock()
+	o.keys = keys
+	o.mu.Unlock()
+	k, ok = keys[kid]
+	if !ok && kid == "" && len(keys) == 1 {
+		for _, v := range keys {
+			k, ok = v, true
+		}
+	}
+	if !ok {
+		return nil, errors.New("unknown id token key " + kid)
+	}
+	return k, nil
+}
+
+// getJSON gets the given URL and unmarshals the JSON response
+func (o *oidcClient) getJSON(ctx context.Context, u, token string, v interface{}) error {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return errors.New("failed to prepare request. Error: " + err.Error())
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := o.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return fmt.Errorf("bad response: %d", res.StatusCode)
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+	}
+	return nil
+}
+
+// sign marshals the given value and signs it for a cookie
+func (o *oidcClient) sign(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	p := base64.RawURLEncoding.EncodeToString(b)
+	m := hmac.New(sha256.New, o.secret)
+	m.Write([]byte(p))
+	return p + "." + base64.RawURLEncoding.EncodeToString(m.Sum(nil)), nil
+}
+
+// verify verifies the given signed cookie value and unmarshals it
+func (o *oidcClient) verify(s string, v interface{}) error {
+	i := strings.LastIndex(s, ".")
+	if i < 0 {
+		return errors.New("invalid signed value")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(s[i+1:])
+	if err != nil {
+		return errors.New("invalid signed value")
+	}
+	m := hmac.New(sha256.New, o.secret)
+	m.Write([]byte(s[:i]))
+	if !hmac.Equal(m.Sum(nil), sig) {
+		return errors.New("invalid signature")
+	}
+	return decodeSegment(s[:i], v)
+}
+
+// decodeSegment decodes the given base64url encoded JSON data
+func decodeSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// setCookie sets or clears (negative max age) the given cookie
+func setCookie(w http.ResponseWriter, req *http.Request, name, value string, maxAge time.Duration) {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     strings.TrimRight(config.Path, "/") + "/",
+		HttpOnly: true,
+		Secure:   requestScheme(req) == "https",
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(maxAge / time.Second),
+	}
+	if maxAge < 0 {
+		c.MaxAge = -1
+	}
+	http.SetCookie(w, c)
+}
+
+// requestScheme returns the scheme of the given request by respecting the proxies
+func requestScheme(req *http.Request) string {
+	if req.TLS != nil || req.Header.Get("X-Forwarded-Proto") == "https" {
+		return "https"
+	}
+	return "http"
+}
+
+// randomString returns a random string for the states, nonces and verifiers
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
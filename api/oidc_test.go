@@ -0,0 +1,225 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	conf "github.com/yieldbot/ferret/config"
+)
+
+// fakeIssuer represents a fake OpenID Connect issuer
+type fakeIssuer struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	idToken   string
+	challenge string
+}
+
+// newFakeIssuer returns a new fake issuer
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                idp.URL,
+			AuthorizationEndpoint: idp.URL + "/authorize",
+			TokenEndpoint:         idp.URL + "/token",
+			JWKSURI:               idp.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []interface{}{map[string]string{
+				"kid": "k1",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		cc := sha256.Sum256([]byte(req.PostFormValue("code_verifier")))
+		if req.PostFormValue("code") != "c0de" || base64.RawURLEncoding.EncodeToString(cc[:]) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.idToken})
+	})
+	idp.Server = httptest.NewServer(mux)
+	return idp
+}
+
+// sign returns an ID token of the given claims which is signed by the given key
+func (idp *fakeIssuer) sign(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	h, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+	c, _ := json.Marshal(claims)
+	s := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	d := sha256.Sum256([]byte(s))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, d[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// login starts a login and returns the state cookie, the state and the nonce
+func (idp *fakeIssuer) login(t *testing.T) (*http.Cookie, string, string) {
+	w := httptest.NewRecorder()
+	LoginHandler(w, httptest.NewRequest(http.MethodGet, "/login?redirect=/search", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected 302, got %d %s", w.Code, w.Body.String())
+	}
+	u, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(u.String(), idp.URL+"/authorize?") {
+		t.Fatalf("unexpected authorization redirect %s", u)
+	}
+	q := u.Query()
+	if q.Get("client_id") != "ferret" || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("unexpected authorization request %s", u)
+	}
+	idp.challenge = q.Get("code_challenge")
+	for _, c := range w.Result().Cookies() {
+		if c.Name == stateCookie {
+			return c, q.Get("state"), q.Get("nonce")
+		}
+	}
+	t.Fatal("missing state cookie")
+	return nil, "", ""
+}
+
+func TestOIDCCallback(t *testing.T) {
+	idp := newFakeIssuer(t)
+	defer idp.Close()
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		unverified bool
+		claims     func(c map[string]interface{})
+		key        *rsa.PrivateKey
+		state      string
+		code       int
+		err        string
+	}{
+		{name: "valid", code: http.StatusFound},
+		{name: "bad signature", key: other, code: http.StatusUnauthorized, err: "invalid id token signature"},
+		{name: "wrong aud", claims: func(c map[string]interface{}) { c["aud"] = "other" }, code: http.StatusUnauthorized, err: "invalid id token audience"},
+		{name: "wrong iss", claims: func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }, code: http.StatusUnauthorized, err: "invalid id token issuer"},
+		{name: "expired token", claims: func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, code: http.StatusUnauthorized, err: "expired id token"},
+		{name: "nonce mismatch", claims: func(c map[string]interface{}) { c["nonce"] = "other" }, code: http.StatusUnauthorized, err: "invalid id token nonce"},
+		{name: "state mismatch", state: "other", code: http.StatusBadRequest, err: "invalid state"},
+		{name: "unverified email", claims: func(c map[string]interface{}) { c["email_verified"] = false }, code: http.StatusForbidden, err: "The email is not verified"},
+		{name: "missing email verification", claims: func(c map[string]interface{}) { delete(c, "email_verified") }, code: http.StatusForbidden, err: "The email is not verified"},
+		{name: "allowed unverified email", unverified: true, claims: func(c map[string]interface{}) { delete(c, "email_verified") }, code: http.StatusFound},
+		{name: "wrong domain", claims: func(c map[string]interface{}) { c["email"] = "jane@evil.com" }, code: http.StatusForbidden, err: "The email domain is not allowed"},
+	}
+	for _, tt := range tests {
+		if err := initOIDC(conf.ListenOIDC{Issuer: idp.URL, ClientID: "ferret", Domains: []string{"example.com"}, UnverifiedEmails: tt.unverified}); err != nil {
+			t.Fatal(err)
+		}
+		cookie, state, nonce := idp.login(t)
+
+		claims := map[string]interface{}{
+			"iss":            idp.URL,
+			"sub":            "1234",
+			"aud":            []string{"ferret", "other"},
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"iat":            time.Now().Unix(),
+			"nonce":          nonce,
+			"email":          "jane@example.com",
+			"email_verified": true,
+			"name":           "Jane",
+			"groups":         []string{"engineering"},
+		}
+		if tt.claims != nil {
+			tt.claims(claims)
+		}
+		key := idp.key
+		if tt.key != nil {
+			key = tt.key
+		}
+		idp.idToken = idp.sign(t, key, claims)
+		if tt.state != "" {
+			state = tt.state
+		}
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/callback?code=c0de&state="+url.QueryEscape(state), nil)
+		req.AddCookie(cookie)
+		CallbackHandler(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d %s", tt.name, tt.code, w.Code, w.Body.String())
+			continue
+		}
+		if tt.err != "" {
+			if !strings.Contains(w.Body.String(), tt.err) {
+				t.Errorf("%s: expected error %q, got %s", tt.name, tt.err, w.Body.String())
+			}
+			continue
+		}
+
+		// The session is started for the valid callbacks
+		if l := w.Header().Get("Location"); l != "/search" {
+			t.Errorf("%s: unexpected redirect %s", tt.name, l)
+		}
+		req = httptest.NewRequest(http.MethodGet, "/v2/search", nil)
+		for _, c := range w.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		s, ok := oidc.session(req)
+		if !ok || s.Email != "jane@example.com" || s.Name != "Jane" || len(s.Groups) != 1 || s.Groups[0] != "engineering" {
+			t.Errorf("%s: unexpected session %+v (%v)", tt.name, s, ok)
+		}
+	}
+	oidc = nil
+}
+
+func TestOIDCCallbackExpiredState(t *testing.T) {
+	idp := newFakeIssuer(t)
+	defer idp.Close()
+	if err := initOIDC(conf.ListenOIDC{Issuer: idp.URL, ClientID: "ferret"}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { oidc = nil }()
+
+	// The state cookie is signed by the server
+	v, err := oidc.sign(oidcState{State: "s", Nonce: "n", Expires: time.Now().Add(-time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []string{v, "forged." + base64.RawURLEncoding.EncodeToString([]byte("sig"))} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/callback?code=c0de&state=s", nil)
+		req.AddCookie(&http.Cookie{Name: stateCookie, Value: c})
+		CallbackHandler(w, req)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid or expired login state") {
+			t.Errorf("expected an invalid state error, got %d %s", w.Code, w.Body.String())
+		}
+	}
+}
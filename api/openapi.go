@@ -0,0 +1,202 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/yieldbot/ferret/search"
+)
+
+// Version is the version of the API which is reported by the OpenAPI document
+var Version = "latest"
+
+// OpenAPIHandler is the handler for the OpenAPI document route
+func OpenAPIHandler(w http.ResponseWriter, req *http.Request) {
+	DataHandler(w, req, openAPI())
+}
+
+// openAPI returns the OpenAPI 3 document of the API routes
+func openAPI() map[string]interface{} {
+	paths := map[string]interface{}{}
+	for _, r := range enabledRoutes() {
+		var params []interface{}
+		for _, p := range r.Params {
+			s := map[string]interface{}{"type": p.Type}
+			if p.Default != nil {
+				s["default"] = p.Default
+			}
+			if p.Enum != nil {
+				s["enum"] = p.Enum
+			}
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          "query",
+				"description": p.Description,
+				"required":    p.Required,
+				"schema":      s,
+			})
+		}
+
+		ct := r.ContentType
+		if ct == "" {
+			ct = "application/json"
+		}
+		status := r.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		res := map[string]interface{}{"description": http.StatusText(status)}
+		if r.Response != nil {
+			res["content"] = map[string]interface{}{
+				ct: map[string]interface{}{"schema": schema(reflect.ValueOf(r.Response))},
+			}
+		}
+		responses := map[string]interface{}{fmt.Sprintf("%d", status): res}
+		errs := r.Errors
+		if !r.Public && authEnabled() {
+			errs = append(append([]int{}, errs...), http.StatusUnauthorized, http.StatusForbidden)
+		}
+		// The searches are rejected by the rate limit and by the concurrency limits
+		// when they can't get a provider slot within the timeout
+		if (!r.Public && limiter != nil) || (r.Searches && search.Limited()) {
+			errs = append(append([]int{}, errs...), http.StatusTooManyRequests)
+		}
+		for _, code := range errs {
+			responses[fmt.Sprintf("%d", code)] = map[string]interface{}{
+				"description": http.StatusText(code),
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/httpError"}},
+				},
+			}
+		}
+
+		op := map[string]interface{}{
+			"summary":     r.Summary,
+			"operationId": operationID(r.method(), r.Path),
+			"responses":   responses,
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if len(r.Body) > 0 {
+			content := map[string]interface{}{}
+			for k, v := range r.Body {
+				content[k] = map[string]interface{}{"schema": schema(reflect.ValueOf(v))}
+			}
+			op["requestBody"] = map[string]interface{}{"required": true, "content": content}
+		}
+		if !r.Public && authEnabled() {
+			op["security"] = []interface{}{
+				map[string]interface{}{"apiKey": []string{}},
+				map[string]interface{}{"basicAuth": []string{}},
+				map[string]interface{}{"session": []string{}},
+			}
+		}
+		pi, ok := paths[r.Path].(map[string]interface{})
+		if !ok {
+			pi = map[string]interface{}{}
+			paths[r.Path] = pi
+		}
+		pi[strings.ToLower(r.method())] = op
+	}
+
+	server := strings.TrimRight(config.Path, "/")
+	if server == "" {
+		server = "/"
+	}
+	components := map[string]interface{}{
+		"schemas": map[string]interface{}{
+			"httpError": schema(reflect.ValueOf(httpError{})),
+		},
+	}
+	if authEnabled() {
+		components["securitySchemes"] = map[string]interface{}{
+			"apiKey":    map[string]interface{}{"type": "http", "scheme": "bearer"},
+			"basicAuth": map[string]interface{}{"type": "http", "scheme": "basic"},
+			"session":   map[string]interface{}{"type": "apiKey", "in": "cookie", "name": sessionCookie},
+		}
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Ferret",
+			"version": Version,
+		},
+		"servers":    []interface{}{map[string]interface{}{"url": server}},
+		"paths":      paths,
+		"components": components,
+	}
+}
+
+// operationID returns the operation id of the given route method and path
+// (i.e. GET /v2/search -> v2Search, POST /v2/tokens -> postV2Tokens)
+func operationID(method, p string) string {
+	var id string
+	if method != http.MethodGet {
+		id = strings.ToLower(method)
+	}
+	for _, v := range strings.FieldsFunc(strings.TrimSuffix(p, ".json"), func(r rune) bool { return r == '/' || r == '.' }) {
+		if id != "" {
+			v = strings.ToUpper(v[:1]) + v[1:]
+		}
+		id += v
+	}
+	return id
+}
+
+// schema returns the JSON schema of the given value.
+// Interface fields are described by their dynamic values.
+func schema(v reflect.Value) map[string]interface{} {
+	if v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return map[string]interface{}{}
+		}
+		return schema(v.Elem())
+	}
+
+	t := v.Type()
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schema(reflect.Zero(t.Elem()))}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object"}
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name := f.Name
+			if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+			props[name] = schema(v.Field(i))
+		}
+		return map[string]interface{}{"type": "object", "properties": props}
+	}
+	return map[string]interface{}{}
+}
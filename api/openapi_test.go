@@ -0,0 +1,151 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	conf "github.com/yieldbot/ferret/config"
+	"github.com/yieldbot/ferret/search"
+)
+
+// documentedMethods returns the documented methods of the paths
+func documentedMethods(t *testing.T) map[string]map[string]bool {
+	dm := map[string]map[string]bool{}
+	paths, ok := openAPI()["paths"].(map[string]interface{})
+	if !ok {
+		t.Fatal("missing paths")
+	}
+	for p, v := range paths {
+		dm[p] = map[string]bool{}
+		for m := range v.(map[string]interface{}) {
+			dm[p][strings.ToUpper(m)] = true
+		}
+	}
+	return dm
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		oidc *oidcClient
+	}{
+		{"without oidc", nil},
+		{"with oidc", &oidcClient{}},
+	} {
+		oidc = c.oidc
+		dm := documentedMethods(t)
+		mux := serveMux()
+
+		// Every route is documented by its method
+		for _, r := range enabledRoutes() {
+			if !dm[r.Path][r.method()] {
+				t.Errorf("%s: %s %s is not documented", c.name, r.method(), r.Path)
+			}
+		}
+		if _, ok := dm["/login"]; ok != (c.oidc != nil) {
+			t.Errorf("%s: unexpected /login document", c.name)
+		}
+
+		for p, methods := range dm {
+			// Every documented operation is served by its route
+			for m := range methods {
+				if _, pattern := mux.Handler(httptest.NewRequest(m, p, nil)); pattern != p {
+					t.Errorf("%s: %s %s is served by %q", c.name, m, p, pattern)
+				}
+			}
+
+			// The methods which are not documented are rejected
+			for _, m := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete} {
+				if methods[m] {
+					continue
+				}
+				w := httptest.NewRecorder()
+				mux.ServeHTTP(w, httptest.NewRequest(m, p, nil))
+				if w.Code != http.StatusMethodNotAllowed {
+					t.Errorf("%s: expected 405 for %s %s, got %d", c.name, m, p, w.Code)
+				}
+				for am := range methods {
+					if !strings.Contains(w.Header().Get("Allow"), am) {
+						t.Errorf("%s: expected %s to be allowed for %s", c.name, am, p)
+					}
+				}
+			}
+		}
+	}
+	oidc = nil
+}
+
+func TestOpenAPIOperations(t *testing.T) {
+	paths := openAPI()["paths"].(map[string]interface{})
+	tokens := paths["/v2/tokens"].(map[string]interface{})
+	for m, id := range map[string]string{"get": "v2Tokens", "post": "postV2Tokens", "delete": "deleteV2Tokens"} {
+		op, ok := tokens[m].(map[string]interface{})
+		if !ok {
+			t.Fatalf("missing %s /v2/tokens", m)
+		}
+		if op["operationId"] != id {
+			t.Errorf("expected operation id %s, got %v", id, op["operationId"])
+		}
+		if _, ok := op["requestBody"]; ok != (m == "post") {
+			t.Errorf("unexpected request body of %s /v2/tokens", m)
+		}
+	}
+
+	gql := paths["/graphql"].(map[string]interface{})
+	rb, ok := gql["post"].(map[string]interface{})["requestBody"].(map[string]interface{})
+	if !ok {
+		t.Fatal("missing request body of POST /graphql")
+	}
+	content := rb["content"].(map[string]interface{})
+	js := content["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+	if _, ok := js["properties"].(map[string]interface{})["query"]; !ok {
+		t.Errorf("missing query property of the request body %v", js)
+	}
+	if _, ok := content["application/graphql"]; !ok {
+		t.Error("missing application/graphql request body")
+	}
+
+	docs := paths["/docs"].(map[string]interface{})["get"].(map[string]interface{})
+	if _, ok := docs["responses"].(map[string]interface{})["302"]; !ok {
+		t.Errorf("expected a redirect response of /docs, got %v", docs["responses"])
+	}
+}
+
+func TestOpenAPITooManyRequests(t *testing.T) {
+	// documented returns whether 429 is documented for the given path or not
+	documented := func(p string) bool {
+		op := openAPI()["paths"].(map[string]interface{})[p].(map[string]interface{})["get"].(map[string]interface{})
+		_, ok := op["responses"].(map[string]interface{})["429"]
+		return ok
+	}
+	if documented("/v2/search") {
+		t.Error("unexpected 429 without the limits")
+	}
+
+	// The concurrency limits reject the searches only
+	search.Init(conf.Config{Search: conf.Search{Concurrency: 2}})
+	defer search.Init(conf.Config{})
+	if !documented("/search") || !documented("/v2/search") {
+		t.Error("expected 429 for the search routes with the concurrency limit")
+	}
+	if documented("/v2/providers") {
+		t.Error("unexpected 429 for the providers route")
+	}
+
+	// The rate limit rejects all the routes which are not public
+	if err := initRateLimit(conf.ListenRateLimit{Rate: 10}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { limiter = nil }()
+	if !documented("/v2/providers") || documented("/healthz") {
+		t.Error("expected 429 for the routes which are not public with the rate limit")
+	}
+}
@@ -0,0 +1,9 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package proto provides the generated code of the gRPC search service.
+// The code is built only with the `grpc` build tag.
+package proto
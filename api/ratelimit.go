@@ -0,0 +1,149 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	conf "github.com/yieldbot/ferret/config"
+)
+
+// limiter is the rate limiter of the clients. It's nil when the rate limit is not configured.
+var limiter *rateLimiter
+
+// rateLimiter represents a token bucket rate limiter per client
+type rateLimiter struct {
+	rate    float64 // tokens per second
+	burst   float64
+	header  string
+	hops    int
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// bucket represents a token bucket
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// initRateLimit initializes the rate limiter by the given configuration
+func initRateLimit(c conf.ListenRateLimit) error {
+	limiter = nil
+	if c.Rate <= 0 {
+		return nil
+	}
+	l := rateLimiter{
+		rate:    float64(c.Rate) / 60,
+		burst:   float64(c.Burst),
+		header:  c.Header,
+		hops:    int(c.Hops),
+		buckets: make(map[string]*bucket),
+		swept:   time.Now(),
+	}
+	if l.burst <= 0 {
+		l.burst = float64(c.Rate)
+	}
+	if l.hops <= 0 {
+		l.hops = 1
+	}
+	limiter = &l
+	return nil
+}
+
+// allow takes a token from the bucket of the given client.
+// It returns the wait duration for the next token when the bucket is empty.
+func (l *rateLimiter) allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep removes the buckets which are full again so the idle clients don't pile up
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for k, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// client returns the client of the given request. Authenticated requests are
+// limited by their credential and the others by their IP address.
+func (l *rateLimiter) client(req *http.Request) string {
+	if u := requestUser(req); u != "" {
+		return "user:" + u
+	}
+	if l.header != "" {
+		// The addresses of the header (i.e. X-Forwarded-For) are appended by the proxies
+		// so the client is taken by the number of the trusted proxy hops from the end.
+		// The addresses before it are set by the client and can't be trusted.
+		var al []string
+		for _, v := range strings.Split(req.Header.Get(l.header), ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				al = append(al, v)
+			}
+		}
+		if len(al) > 0 {
+			i := len(al) - l.hops
+			if i < 0 {
+				i = 0
+			}
+			return "ip:" + al[i]
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// RateLimitHandler limits the request rate of the clients for the given handler
+func RateLimitHandler(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if limiter == nil {
+			h(w, req)
+			return
+		}
+		ok, wait := limiter.allow(limiter.client(req))
+		if !ok {
+			rateLimitedTotal.Inc("rejected")
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int64(math.Ceil(wait.Seconds()))))
+			ErrorHandler(w, req, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		rateLimitedTotal.Inc("allowed")
+		h(w, req)
+	}
+}
@@ -0,0 +1,45 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	conf "github.com/yieldbot/ferret/config"
+)
+
+func TestRateLimitClient(t *testing.T) {
+	tests := []struct {
+		hops   int64
+		header string
+		client string
+	}{
+		{0, "", "ip:192.0.2.1"},
+		{0, "203.0.113.9", "ip:203.0.113.9"},
+		// The client can't spoof the address by the first entry
+		{0, "10.0.0.1, 203.0.113.9", "ip:203.0.113.9"},
+		{1, "10.0.0.1,203.0.113.9, ", "ip:203.0.113.9"},
+		{2, "10.0.0.1, 203.0.113.9, 198.51.100.7", "ip:203.0.113.9"},
+		{3, "203.0.113.9, 198.51.100.7", "ip:203.0.113.9"},
+	}
+	for _, tt := range tests {
+		if err := initRateLimit(conf.ListenRateLimit{Rate: 60, Header: "X-Forwarded-For", Hops: tt.hops}); err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/v2/search", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if tt.header != "" {
+			req.Header.Set("X-Forwarded-For", tt.header)
+		}
+		if c := limiter.client(req); c != tt.client {
+			t.Errorf("%d hops %q: expected %s, got %s", tt.hops, tt.header, tt.client, c)
+		}
+	}
+	limiter = nil
+}
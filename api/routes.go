@@ -0,0 +1,282 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/yieldbot/ferret/search"
+)
+
+// route represents an API route.
+// The routes are used for both the HTTP handlers and the OpenAPI document.
+// A path may have several routes with different methods. The method is GET unless Method is set.
+// The routes which are not public require credentials when the auth is configured.
+// Searches marks the routes which search the providers under their concurrency limits.
+// The response content type is JSON unless ContentType is set and
+// the response status is 200 unless Status is set.
+// Body describes the request body by its content types.
+// The routes are registered only if Enabled is nil or it returns true.
+type route struct {
+	Path        string
+	Method      string
+	Summary     string
+	Handler     http.HandlerFunc
+	Params      []param
+	Body        map[string]interface{}
+	Response    interface{}
+	ContentType string
+	Status      int
+	Errors      []int
+	Public      bool
+	Searches    bool
+	Enabled     func() bool
+}
+
+// param represents a query parameter of an API route
+type param struct {
+	Name        string
+	Description string
+	Type        string
+	Default     interface{}
+	Enum        []string
+	Required    bool
+}
+
+var (
+	paramProvider = param{Name: "provider", Description: "Name of the search provider", Type: "string", Required: true}
+	paramKeyword  = param{Name: "keyword", Description: "Search keyword", Type: "string", Required: true}
+	paramPage     = param{Name: "page", Description: "Page number", Type: "integer", Default: 1}
+	paramLimit    = param{Name: "limit", Description: "Number of the results per page", Type: "integer", Default: 10}
+	paramLimitV1  = param{Name: "limit", Description: "Number of the results per page", Type: "integer", Default: 1}
+	paramTimeout  = param{Name: "timeout", Description: "Search timeout as a duration (i.e. 5000ms)", Type: "string", Default: "5000ms"}
+	paramOutput   = param{Name: "output", Description: "Output format of the JSON data", Type: "string", Enum: []string{"pretty"}}
+	paramCallback = param{Name: "callback", Description: "JSONP callback function name", Type: "string"}
+
+	paramQuery         = param{Name: "query", Description: "GraphQL query document", Type: "string", Required: true}
+	paramVariables     = param{Name: "variables", Description: "GraphQL variables as a JSON object", Type: "string"}
+	paramOperationName = param{Name: "operationName", Description: "Name of the GraphQL operation to run", Type: "string"}
+)
+
+// tokenBody represents the request body of the token route
+type tokenBody struct {
+	Token string `json:"token"`
+}
+
+// method returns the method of the route
+func (r route) method() string {
+	if r.Method == "" {
+		return http.MethodGet
+	}
+	return r.Method
+}
+
+// enabledRoutes returns the enabled API routes
+func enabledRoutes() []route {
+	var rl []route
+	for _, r := range routes() {
+		if r.Enabled == nil || r.Enabled() {
+			rl = append(rl, r)
+		}
+	}
+	return rl
+}
+
+// methodHandler returns the handler of the given routes of the same path.
+// The requests are dispatched by their methods and HEAD requests are served by the GET route.
+func methodHandler(rl []route) http.HandlerFunc {
+	handlers := map[string]http.HandlerFunc{}
+	var allow []string
+	for _, r := range rl {
+		h := r.Handler
+		if !r.Public {
+			h = AuthHandler(RateLimitHandler(h))
+		}
+		handlers[r.method()] = MetricsHandler(r.Path, h)
+		allow = append(allow, r.method())
+	}
+	if h, ok := handlers[http.MethodGet]; ok {
+		if _, ok := handlers[http.MethodHead]; !ok {
+			handlers[http.MethodHead] = h
+		}
+	}
+	return func(w http.ResponseWriter, req *http.Request) {
+		if h, ok := handlers[req.Method]; ok {
+			h(w, req)
+			return
+		}
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		ErrorHandler(w, req, http.StatusMethodNotAllowed, "invalid method")
+	}
+}
+
+// oidcEnabled checks whether the OIDC routes are enabled or not
+func oidcEnabled() bool {
+	return oidc != nil
+}
+
+// routes returns the API routes
+func routes() []route {
+	return []route{
+		{
+			Path:     "/search",
+			Summary:  "Search by the given provider",
+			Handler:  SearchHandler,
+			Params:   []param{paramProvider, paramKeyword, paramPage, paramLimitV1, paramTimeout, paramOutput, paramCallback},
+			Response: search.Results{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusGatewayTimeout},
+			Searches: true,
+		},
+		{
+			Path:     "/providers",
+			Summary:  "List the search providers",
+			Handler:  ProvidersHandler,
+			Params:   []param{paramOutput, paramCallback},
+			Response: []provider{},
+			Errors:   []int{http.StatusInternalServerError},
+		},
+		{
+			Path:     "/v2/search",
+			Summary:  "Search by the given provider and wrap the results by an envelope",
+			Handler:  SearchV2Handler,
+			Params:   []param{paramProvider, paramKeyword, paramPage, paramLimit, paramTimeout, paramOutput, paramCallback},
+			Response: envelope{Query: &envelopeQuery{}, Results: search.Results{}},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusGatewayTimeout},
+			Searches: true,
+		},
+		{
+			Path:     "/v2/providers",
+			Summary:  "List the search providers and wrap them by an envelope",
+			Handler:  ProvidersV2Handler,
+			Params:   []param{paramOutput, paramCallback},
+			Response: envelope{Results: []provider{}},
+			Errors:   []int{http.StatusInternalServerError},
+		},
+		{
+			Path:     "/v2/tokens",
+			Summary:  "List the providers which accept delegated credentials",
+			Handler:  TokensHandler,
+			Params:   []param{paramOutput, paramCallback},
+			Response: envelope{Results: []delegation{}},
+			Errors:   []int{http.StatusUnauthorized, http.StatusNotFound},
+		},
+		{
+			Path:     "/v2/tokens",
+			Method:   http.MethodPost,
+			Summary:  "Link a token of the given provider to the authenticated user",
+			Handler:  TokensHandler,
+			Params:   []param{paramProvider, paramOutput, paramCallback},
+			Body:     map[string]interface{}{"application/json": tokenBody{}, "application/x-www-form-urlencoded": tokenBody{}},
+			Response: envelope{Results: []delegation{}},
+			Errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			Path:     "/v2/tokens",
+			Method:   http.MethodDelete,
+			Summary:  "Unlink the token of the given provider from the authenticated user",
+			Handler:  TokensHandler,
+			Params:   []param{paramProvider, paramOutput, paramCallback},
+			Response: envelope{Results: []delegation{}},
+			Errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			Path:     "/graphql",
+			Summary:  "Run a GraphQL query against the providers and search fields",
+			Handler:  GraphQLHandler,
+			Params:   []param{paramQuery, paramVariables, paramOperationName, paramTimeout, paramOutput, paramCallback},
+			Response: graphQLResponse{Data: map[string]interface{}{}, Errors: []graphQLError{}},
+			Errors:   []int{http.StatusBadRequest},
+		},
+		{
+			Path:     "/graphql",
+			Method:   http.MethodPost,
+			Summary:  "Run a GraphQL query of the request body against the providers and search fields",
+			Handler:  GraphQLHandler,
+			Params:   []param{paramTimeout, paramOutput, paramCallback},
+			Body:     map[string]interface{}{"application/json": graphQLRequest{Variables: map[string]interface{}{}}, "application/graphql": ""},
+			Response: graphQLResponse{Data: map[string]interface{}{}, Errors: []graphQLError{}},
+			Errors:   []int{http.StatusBadRequest},
+		},
+		{
+			Path:     "/openapi.json",
+			Summary:  "OpenAPI document of the API",
+			Handler:  OpenAPIHandler,
+			Params:   []param{paramOutput},
+			Response: map[string]interface{}{},
+			Public:   true,
+		},
+		{
+			Path:        "/metrics",
+			Summary:     "Metrics of the API routes and the search providers in the Prometheus text format",
+			Handler:     PrometheusHandler,
+			Response:    "",
+			ContentType: "text/plain",
+			Public:      true,
+		},
+		{
+			Path:     "/healthz",
+			Summary:  "Liveness of the process",
+			Handler:  HealthzHandler,
+			Params:   []param{paramOutput},
+			Response: map[string]string{},
+			Public:   true,
+		},
+		{
+			Path:     "/readyz",
+			Summary:  "Readiness by the cached probe results of the providers. It returns 503 with the same body when a required provider is unreachable",
+			Handler:  ReadyzHandler,
+			Params:   []param{paramOutput},
+			Response: map[string]string{},
+			Public:   true,
+		},
+		{
+			Path:     "/v2/readiness",
+			Summary:  "List the cached probe results of the providers and wrap them by an envelope",
+			Handler:  ReadinessV2Handler,
+			Params:   []param{paramOutput, paramCallback},
+			Response: envelope{Results: []probe{}},
+		},
+		{
+			Path:    "/docs",
+			Summary: "Redirect to the API documentation page",
+			Handler: DocsHandler,
+			Status:  http.StatusFound,
+			Public:  true,
+		},
+		{
+			Path:    "/login",
+			Summary: "Redirect to the identity provider to sign in",
+			Handler: LoginHandler,
+			Status:  http.StatusFound,
+			Errors:  []int{http.StatusInternalServerError, http.StatusBadGateway},
+			Public:  true,
+			Enabled: oidcEnabled,
+		},
+		{
+			Path:    "/callback",
+			Summary: "Complete the sign in by the authorization code of the identity provider",
+			Handler: CallbackHandler,
+			Params: []param{
+				{Name: "code", Description: "Authorization code", Type: "string", Required: true},
+				{Name: "state", Description: "State of the authorization request", Type: "string", Required: true},
+			},
+			Status:  http.StatusFound,
+			Errors:  []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+			Public:  true,
+			Enabled: oidcEnabled,
+		},
+		{
+			Path:    "/logout",
+			Summary: "End the session and redirect to the home page",
+			Handler: LogoutHandler,
+			Status:  http.StatusFound,
+			Public:  true,
+			Enabled: oidcEnabled,
+		},
+	}
+}
@@ -0,0 +1,303 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	conf "github.com/yieldbot/ferret/config"
+	"github.com/yieldbot/ferret/search"
+)
+
+// tokens is the store of the delegated credentials. It's nil when the delegation is not configured.
+var tokens *tokenStore
+
+// tokenStore represents the encrypted store of the delegated credentials
+type tokenStore struct {
+	mu     sync.Mutex
+	file   string
+	aead   cipher.AEAD
+	tokens map[string]map[string]string
+}
+
+// delegation represents the delegated credential status of a provider
+type delegation struct {
+	Provider string `json:"provider"`
+	Title    string `json:"title"`
+	Linked   bool   `json:"linked"`
+	Fallback bool   `json:"fallback"`
+}
+
+// initTokens initializes the delegated credential store by the given delegation
+// and auth configurations
+func initTokens(c conf.ListenDelegation, al []conf.ListenAuth) error {
+	tokens = nil
+	if err := checkFallback(); err != nil {
+		return err
+	}
+	if c.File == "" {
+		return nil
+	}
+	if c.Secret == "" {
+		return errors.New("missing delegation secret")
+	}
+
+	// The tokens are stored by the credential names so they should be stable and unique
+	names := make(map[string]bool)
+	for i, v := range al {
+		n := v.Name
+		if n == "" {
+			n = v.Username
+		}
+		if n == "" {
+			return fmt.Errorf("missing name of auth #%d. The auth credentials should be named when the delegation is configured", i+1)
+		}
+		if names[n] {
+			return errors.New("duplicate auth name " + n + ". The auth credentials should have unique names when the delegation is configured")
+		}
+		names[n] = true
+	}
+
+	key := sha256.Sum256([]byte(c.Secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	ts := tokenStore{
+		file:   c.File,
+		aead:   aead,
+		tokens: make(map[string]map[string]string),
+	}
+
+	data, err := ioutil.ReadFile(c.File)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.New("failed to read the delegation file. Error: " + err.Error())
+	} else if err == nil {
+		if err := json.Unmarshal(data, &ts.tokens); err != nil {
+			return errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+		}
+	}
+
+	tokens = &ts
+	return nil
+}
+
+// checkFallback checks the delegated providers which don't fall back to the shared
+// credential. They require the auth since the anonymous requests would use the shared credential.
+func checkFallback() error {
+	if authEnabled() {
+		return nil
+	}
+	for _, v := range providers {
+		if p, err := search.ProviderByName(v.Name); err == nil && p.Delegated && !p.Fallback {
+			return errors.New("provider " + v.Name + " requires a delegated credential (fallback is false) but the auth is not configured")
+		}
+	}
+	return nil
+}
+
+// get returns the token of the given user and provider
+func (ts *tokenStore) get(user, provider string) (string, error) {
+	ts.mu.Lock()
+	v, ok := ts.tokens[user][provider]
+	ts.mu.Unlock()
+	if !ok {
+		return "", nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(v)
+	if err != nil || len(data) < ts.aead.NonceSize() {
+		return "", errors.New("invalid token data")
+	}
+	n := ts.aead.NonceSize()
+	// The user and the provider are authenticated so the tokens can't be swapped
+	pt, err := ts.aead.Open(nil, data[:n], data[n:], []byte(user+"\x00"+provider))
+	if err != nil {
+		return "", errors.New("failed to decrypt the token")
+	}
+	return string(pt), nil
+}
+
+// set sets the token of the given user and provider. An empty token removes it.
+func (ts *tokenStore) set(user, provider, token string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if token == "" {
+		delete(ts.tokens[user], provider)
+		if len(ts.tokens[user]) == 0 {
+			delete(ts.tokens, user)
+		}
+	} else {
+		nonce := make([]byte, ts.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		data := ts.aead.Seal(nonce, nonce, []byte(token), []byte(user+"\x00"+provider))
+		if ts.tokens[user] == nil {
+			ts.tokens[user] = make(map[string]string)
+		}
+		ts.tokens[user][provider] = base64.StdEncoding.EncodeToString(data)
+	}
+
+	// Save atomically
+	data, err := json.Marshal(ts.tokens)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(ts.file), ".ferret-tokens")
+	if err != nil {
+		return errors.New("failed to save the delegation file. Error: " + err.Error())
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return errors.New("failed to save the delegation file. Error: " + err.Error())
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return errors.New("failed to save the delegation file. Error: " + err.Error())
+	}
+	if err := os.Rename(f.Name(), ts.file); err != nil {
+		os.Remove(f.Name())
+		return errors.New("failed to save the delegation file. Error: " + err.Error())
+	}
+	return nil
+}
+
+// requestUser returns the identity of the authenticated user of the given request
+func requestUser(req *http.Request) string {
+	c, _ := req.Context().Value(credentialKey{}).(credential)
+	return c.id
+}
+
+// delegatedToken returns the user and the delegated token of the given request and provider
+func delegatedToken(req *http.Request, provider string) (string, string) {
+	user := requestUser(req)
+	if tokens == nil || user == "" {
+		return user, ""
+	}
+	if p, err := search.ProviderByName(provider); err != nil || !p.Delegated {
+		return user, ""
+	}
+	t, err := tokens.get(user, provider)
+	if err != nil {
+		log.Printf("failed to get the %s token of %s due to %s", provider, user, err.Error())
+	}
+	return user, t
+}
+
+// delegations returns the delegated credential status of the providers for the given request
+func delegations(req *http.Request) []delegation {
+	dl := []delegation{}
+	user := requestUser(req)
+	for _, v := range accessProviders(req) {
+		p, err := search.ProviderByName(v.Name)
+		if err != nil || !p.Delegated {
+			continue
+		}
+		t, _ := tokens.get(user, v.Name)
+		dl = append(dl, delegation{
+			Provider: v.Name,
+			Title:    v.Title,
+			Linked:   t != "",
+			Fallback: p.Fallback,
+		})
+	}
+	return dl
+}
+
+// TokensHandler is the handler for the delegated credentials route.
+// GET lists the providers which accept delegated credentials, POST links a token
+// (`token` form value or JSON field) and DELETE unlinks the token of the given provider.
+func TokensHandler(w http.ResponseWriter, req *http.Request) {
+	if tokens == nil {
+		ErrorHandler(w, req, http.StatusNotFound, "delegation is not configured")
+		return
+	}
+	user := requestUser(req)
+	if user == "" {
+		ErrorHandler(w, req, http.StatusUnauthorized, "delegated credentials require authentication")
+		return
+	}
+
+	if req.Method == http.MethodPost || req.Method == http.MethodDelete {
+		pn := req.URL.Query().Get("provider")
+		p, err := search.ProviderByName(pn)
+		if err != nil || !checkProvider(pn) {
+			ErrorHandler(w, req, http.StatusBadRequest, "invalid provider")
+			return
+		}
+		if !accessProvider(req, pn) {
+			ErrorHandler(w, req, http.StatusForbidden, "access denied to provider "+pn)
+			return
+		}
+		if !p.Delegated {
+			ErrorHandler(w, req, http.StatusBadRequest, "provider "+pn+" doesn't accept delegated credentials")
+			return
+		}
+
+		var token string
+		if req.Method == http.MethodPost {
+			if strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+				var body struct {
+					Token string `json:"token"`
+				}
+				if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, 1<<16)).Decode(&body); err != nil {
+					ErrorHandler(w, req, http.StatusBadRequest, "failed to unmarshal JSON data. Error: "+err.Error())
+					return
+				}
+				token = body.Token
+			} else {
+				req.Body = http.MaxBytesReader(w, req.Body, 1<<16)
+				token = req.PostFormValue("token")
+			}
+			token = strings.TrimSpace(token)
+			if token == "" {
+				ErrorHandler(w, req, http.StatusBadRequest, "missing token")
+				return
+			}
+		}
+		if err := tokens.set(user, pn, token); err != nil {
+			ErrorHandler(w, req, http.StatusInternalServerError, err.Error())
+			return
+		}
+	} else if req.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		ErrorHandler(w, req, http.StatusMethodNotAllowed, "invalid method")
+		return
+	}
+
+	dl := delegations(req)
+	DataHandler(w, req, envelope{
+		Pagination: &envelopePagination{
+			Page:  1,
+			Limit: len(dl),
+			Total: len(dl),
+		},
+		Warnings: []string{},
+		Results:  dl,
+	})
+}
@@ -0,0 +1,118 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	conf "github.com/yieldbot/ferret/config"
+	"github.com/yieldbot/ferret/search"
+	"golang.org/x/net/context"
+)
+
+func TestInitTokensNames(t *testing.T) {
+	dc := conf.ListenDelegation{File: filepath.Join(t.TempDir(), "tokens.json"), Secret: "s"}
+	tests := []struct {
+		name string
+		auth []conf.ListenAuth
+		err  string
+	}{
+		{"named", []conf.ListenAuth{{Name: "ci", Key: "k1"}, {Username: "jane", Password: "p"}}, ""},
+		{"unnamed key", []conf.ListenAuth{{Name: "ci", Key: "k1"}, {Key: "k2"}}, "missing name of auth #2"},
+		{"duplicate name", []conf.ListenAuth{{Name: "jane", Key: "k1"}, {Username: "jane", Password: "p"}}, "duplicate auth name jane"},
+	}
+	for _, tt := range tests {
+		err := initTokens(dc, tt.auth)
+		if tt.err == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		} else if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.err, err)
+		}
+	}
+
+	// The names are not required without the delegation
+	if err := initTokens(conf.ListenDelegation{}, []conf.ListenAuth{{Key: "k1"}, {Key: "k2"}}); err != nil {
+		t.Error(err)
+	}
+	tokens = nil
+}
+
+func TestRequestUser(t *testing.T) {
+	if err := initAuth([]conf.ListenAuth{{Name: "jane@example.com", Key: "k1"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { credentials = nil }()
+
+	var user string
+	h := AuthHandler(func(w http.ResponseWriter, req *http.Request) { user = requestUser(req) })
+	req := httptest.NewRequest(http.MethodGet, "/v2/tokens", nil)
+	req.Header.Set("X-API-Key", "k1")
+	h(httptest.NewRecorder(), req)
+	if user != "key:jane@example.com" {
+		t.Errorf("unexpected user %s", user)
+	}
+
+	// The OIDC users don't share the identities of the API keys
+	if err := initOIDC(conf.ListenOIDC{Issuer: "https://accounts.example.com", ClientID: "ferret"}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { oidc = nil }()
+	v, err := oidc.sign(session{Email: "jane@example.com", Expires: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/v2/tokens", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: v})
+	h(httptest.NewRecorder(), req)
+	if user != "oidc:jane@example.com" {
+		t.Errorf("unexpected user %s", user)
+	}
+}
+
+// delegatedProvider represents a delegated provider for the tests
+type delegatedProvider struct {
+	name      string
+	delegated bool
+	fallback  bool
+}
+
+// Search makes a search
+func (p *delegatedProvider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+	return nil, nil
+}
+
+func TestCheckFallback(t *testing.T) {
+	for _, p := range []*delegatedProvider{{name: "test-fallback", delegated: true, fallback: true}, {name: "test-delegated", delegated: true}} {
+		if err := search.ProviderRegister(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() { providers = nil }()
+
+	providers = []provider{{Name: "test-fallback"}}
+	if err := checkFallback(); err != nil {
+		t.Error(err)
+	}
+	providers = append(providers, provider{Name: "test-delegated"})
+	if err := checkFallback(); err == nil || !strings.Contains(err.Error(), "test-delegated") {
+		t.Errorf("expected an error for the provider without a fallback, got %v", err)
+	}
+
+	// The auth enables the delegated credentials
+	if err := initAuth([]conf.ListenAuth{{Name: "ci", Key: "k1"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { credentials = nil }()
+	if err := checkFallback(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/yieldbot/ferret/search"
+)
+
+// envelope represents the structure of the v2 API responses
+type envelope struct {
+	Query      *envelopeQuery      `json:"query,omitempty"`
+	Pagination *envelopePagination `json:"pagination"`
+	Elapsed    int64               `json:"elapsed"`
+	Cache      string              `json:"cache,omitempty"`
+	Warnings   []string            `json:"warnings"`
+	Results    interface{}         `json:"results"`
+}
+
+// envelopeQuery represents the query echo of the v2 API responses
+type envelopeQuery struct {
+	Provider string `json:"provider"`
+	Keyword  string `json:"keyword"`
+	Page     int    `json:"page"`
+	Limit    int    `json:"limit"`
+	Timeout  int64  `json:"timeout"`
+}
+
+// envelopePagination represents the pagination of the v2 API responses
+type envelopePagination struct {
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+	Total int    `json:"total"`
+	Next  string `json:"next,omitempty"`
+}
+
+// SearchV2Handler is the handler for the v2 search route
+func SearchV2Handler(w http.ResponseWriter, req *http.Request) {
+
+	// Search
+	start := time.Now()
+	q := search.Query{
+		Provider: req.URL.Query().Get("provider"),
+		Keyword:  req.URL.Query().Get("keyword"),
+		Page:     search.ParsePage(req.URL.Query().Get("page")),
+		Timeout:  search.ParseTimeout(req.URL.Query().Get("timeout")),
+		Limit:    search.ParseLimit(req.URL.Query().Get("limit")),
+	}
+
+	// Check the provider
+	if !checkProvider(q.Provider) {
+		ErrorHandler(w, req, http.StatusBadRequest, "invalid provider")
+		return
+	}
+	if !accessProvider(req, q.Provider) {
+		ErrorHandler(w, req, http.StatusForbidden, "access denied to provider "+q.Provider)
+		return
+	}
+	q.User, q.Token = delegatedToken(req, q.Provider)
+
+	if err := q.Do(); err != nil {
+		ErrorHandler(w, req, q.HTTPStatus, err.Error())
+		return
+	}
+
+	// Prepare the envelope
+	results := q.Results
+	if results == nil {
+		results = search.Results{}
+	}
+	e := envelope{
+		Query: &envelopeQuery{
+			Provider: q.Provider,
+			Keyword:  q.Keyword,
+			Page:     q.Page,
+			Limit:    q.Limit,
+			Timeout:  int64(q.Timeout / time.Millisecond),
+		},
+		Pagination: &envelopePagination{
+			Page:  q.Page,
+			Limit: q.Limit,
+			Total: q.Total,
+		},
+		Elapsed:  int64(time.Since(start) / time.Millisecond),
+		Cache:    q.Cache,
+		Warnings: q.Warnings,
+		Results:  results,
+	}
+	if e.Cache == "" {
+		e.Cache = "none"
+	}
+	if e.Warnings == nil {
+		e.Warnings = []string{}
+	}
+
+	// There is a next page if the total says so or, when the total is unknown, the page is full
+	if (q.Total > 0 && q.Page*q.Limit < q.Total) || (q.Total == 0 && len(results) >= q.Limit) {
+		v := url.Values{}
+		for k, vl := range req.URL.Query() {
+			v[k] = vl
+		}
+		v.Set("page", fmt.Sprintf("%d", q.Page+1))
+		e.Pagination.Next = req.URL.Path + "?" + v.Encode()
+	}
+
+	DataHandler(w, req, e)
+}
+
+// ProvidersV2Handler is the handler for the v2 providers route
+func ProvidersV2Handler(w http.ResponseWriter, req *http.Request) {
+	pl := accessProviders(req)
+	if pl == nil {
+		pl = []provider{}
+	}
+	DataHandler(w, req, envelope{
+		Pagination: &envelopePagination{
+			Page:  1,
+			Limit: len(pl),
+			Total: len(pl),
+		},
+		Warnings: []string{},
+		Results:  pl,
+	})
+}
+
+// DataHandler marshals the given data and handles the HTTP response
+func DataHandler(w http.ResponseWriter, req *http.Request, v interface{}) {
+	var data []byte
+	var err error
+	if req.URL.Query().Get("output") == "pretty" {
+		data, err = json.MarshalIndent(v, "", "  ")
+	} else {
+		data, err = json.Marshal(v)
+	}
+	if err != nil {
+		ErrorHandler(w, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ResponseHandler(w, req, data)
+}
+
+// ErrorHandler handles HTTP error responses
+func ErrorHandler(w http.ResponseWriter, req *http.Request, statusCode int, message string) {
+	if statusCode == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+		w.Header().Set("Retry-After", "1")
+	}
+	w.WriteHeader(statusCode)
+	data, _ := json.Marshal(httpError{
+		StatusCode: statusCode,
+		Error:      http.StatusText(statusCode),
+		Message:    message,
+	})
+	ResponseHandler(w, req, data)
+}
@@ -15,6 +15,7 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/rakyll/statik/fs"
 	conf "github.com/yieldbot/ferret/config"
@@ -24,12 +25,14 @@ import (
 
 var (
 	config   conf.Assets
+	listen   conf.Listen
 	statikFS http.FileSystem
 )
 
 // Init initializes the api
 func Init(c conf.Config) {
 	config = c.Assets
+	listen = c.Listen
 
 	var err error
 	statikFS, err = fs.New()
@@ -61,9 +64,18 @@ func IndexHandler(w http.ResponseWriter, req *http.Request) {
 	data := struct {
 		GATrackingCode string
 		Menu           conf.AssetsMenu
+		Login          string
+		Logout         string
+		Tokens         bool
 	}{
 		GATrackingCode: config.GATrackingCode,
 		Menu:           config.Menu,
+		Tokens:         listen.Delegation.File != "",
+	}
+	if listen.OIDC.Issuer != "" {
+		lpp := strings.TrimRight(listen.Path, "/")
+		data.Login = lpp + "/login"
+		data.Logout = lpp + "/logout"
 	}
 
 	if err := t.Execute(w, data); err != nil {
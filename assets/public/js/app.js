@@ -5,7 +5,7 @@
  */
 
 /* jslint browser: true */
-/* global document: false, $: false, Rx: false, unescape: false */
+/* global document: false, $: false, Rx: false, unescape: false, sessionStorage: false */
 'use strict';
 
 // Create the module
@@ -18,12 +18,14 @@ var app = function app() {
   // Init vars
   var serverUrl    = location.protocol + '//' + location.hostname + ':' + location.port,
       appPath      = location.pathname || '/',
+      dataType     = 'json',
       providerList = [];
 
   // for debugging
   if(location.protocol == 'file:') {
     serverUrl = 'http://localhost:3030';
     appPath = '/';
+    dataType = 'jsonp';
   }
 
   // init initializes the app
@@ -41,16 +43,54 @@ var app = function app() {
           return;
         }
         providersPrepare();
+        tokensPrepare();
         listen();
         urlQueryHandler();
       },
       function(err) {
         var e = parseError(err);
+        if(e.code == 401) {
+          // Sign in by the identity provider if it's configured
+          var login = $('body').attr('data-login');
+          if(login && !sessionStorage.getItem('ferretApiKey')) {
+            location.href = login + '?redirect=' + encodeURIComponent(location.pathname + location.search);
+            return;
+          }
+          authPrepare();
+          return;
+        }
         critical("Could not get the available providers due to " + e.message  + " (" + e.code + ")");
       }
     );
   }
 
+  // authHeaders returns the authorization headers of the API requests
+  function authHeaders() {
+    var key = sessionStorage.getItem('ferretApiKey');
+    return key ? {'Authorization': 'Bearer ' + key} : {};
+  }
+
+  // authPrepare prepares UI for the API key
+  function authPrepare() {
+    sessionStorage.removeItem('ferretApiKey');
+    $('#searchAlerts').html(
+      '<form id="authForm" class="form-inline search-alert">' +
+      '<input id="authKey" type="password" class="form-control" placeholder="API key" autocomplete="off"> ' +
+      '<button type="submit" class="btn btn-default">Sign in</button>' +
+      '</form>'
+    );
+    $('#authForm').submit(function(e) {
+      e.preventDefault();
+      var key = $('#authKey').val();
+      if(key) {
+        sessionStorage.setItem('ferretApiKey', key);
+        $('#searchAlerts').empty();
+        init();
+      }
+    });
+    $('#authKey').focus();
+  }
+
   // listen listens search requests for the given providers
   function listen() {
 
@@ -100,11 +140,69 @@ var app = function app() {
   function providersGet() {
     return $.ajax({
       url:      serverUrl+appPath+'providers',
-      dataType: 'jsonp',
+      dataType: dataType,
       method:   'GET',
+      headers:  authHeaders(),
+      dataFilter: emptyFilter
     }).promise();
   }
 
+  // tokensPrepare prepares UI for the delegated tokens
+  function tokensPrepare() {
+    $('#tokensModal').on('show.bs.modal', function() {
+      tokensRequest('GET');
+    });
+  }
+
+  // tokensRequest lists, links or unlinks (by the given method) the delegated tokens
+  function tokensRequest(method, provider, token) {
+    var url = serverUrl+appPath+'v2/tokens';
+    if(provider) {
+      url += '?provider=' + encodeURIComponent(provider);
+    }
+    $('#tokensAlerts').empty();
+    $.ajax({
+      url:      url,
+      dataType: 'json',
+      method:   method,
+      headers:  authHeaders(),
+      data:     (method == 'POST') ? {token: token} : undefined
+    }).then(
+      function(data) {
+        var content = '';
+        $.map(data.results || [], function(v) {
+          content += '<tr data-provider="' + encodeHtmlEntity(v.provider) + '">';
+          content += '<td>' + encodeHtmlEntity(v.title) + '<br><span class="ts">' + (v.linked ? 'linked' : (v.fallback ? 'shared token' : 'not linked')) + '</span></td>';
+          content += '<td><input type="password" class="form-control input-sm" placeholder="Token" autocomplete="off"></td>';
+          content += '<td class="text-right"><button class="btn btn-default btn-sm" data-action="link">Link</button>';
+          content += (v.linked ? ' <button class="btn btn-default btn-sm" data-action="unlink">Unlink</button>' : '') + '</td>';
+          content += '</tr>';
+        });
+        $('#tokensList').html(content || '<tr><td>There is no any provider which accepts tokens</td></tr>');
+        $('#tokensList button').click(function() {
+          var row = $(this).closest('tr');
+          if($(this).attr('data-action') == 'link') {
+            var t = row.find('input').val();
+            if(t) {
+              tokensRequest('POST', row.attr('data-provider'), t);
+            }
+          } else {
+            tokensRequest('DELETE', row.attr('data-provider'));
+          }
+        });
+      },
+      function(err) {
+        var e = parseError(err);
+        $('#tokensAlerts').html($('<div class="alert alert-danger" role="alert">').text(e.message));
+      }
+    );
+  }
+
+  // emptyFilter handles the empty API responses (i.e. no results)
+  function emptyFilter(data) {
+    return data || 'null';
+  }
+
   // providersPrepare prepares UI for providers
   function providersPrepare() {
     // Iterate providers and prepare DOM elements
@@ -126,8 +224,10 @@ var app = function app() {
   function search(provider, keyword) {
     return $.ajax({
       url:      serverUrl+appPath+'search',
-      dataType: 'jsonp',
+      dataType: dataType,
       method:   'GET',
+      headers:  authHeaders(),
+      dataFilter: emptyFilter,
       data: {
         provider: (''+provider),
         keyword:  (''+keyword),
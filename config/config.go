@@ -29,18 +29,71 @@ type Config struct {
 
 // Search represents the structure of the config search field
 type Search struct {
-	GotoCmd    string        `yaml:"gotoCmd"`
-	TimeoutStr string        `yaml:"timeout"`
-	Timeout    time.Duration `yaml:"-"`
+	GotoCmd     string        `yaml:"gotoCmd"`
+	TimeoutStr  string        `yaml:"timeout"`
+	Timeout     time.Duration `yaml:"-"`
+	Concurrency int64         `yaml:"concurrency"`
 }
 
 // Listen represents the structure of the config listen field
 type Listen struct {
-	Address   string `yaml:"address"`
-	Path      string `yaml:"path"`
+	Address    string           `yaml:"address"`
+	GRPC       string           `yaml:"grpc"`
+	Path       string           `yaml:"path"`
+	Providers  string           `yaml:"providers"`
+	Auth       []ListenAuth     `yaml:"auth"`
+	OIDC       ListenOIDC       `yaml:"oidc"`
+	Delegation ListenDelegation `yaml:"delegation"`
+	RateLimit  ListenRateLimit  `yaml:"rateLimit"`
+	Readiness  ListenReadiness  `yaml:"readiness"`
+}
+
+// ListenAuth represents the structure of the config listen auth field
+type ListenAuth struct {
+	Name      string `yaml:"name"`
+	Key       string `yaml:"key"`
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
 	Providers string `yaml:"providers"`
 }
 
+// ListenOIDC represents the structure of the config listen oidc field
+type ListenOIDC struct {
+	Issuer           string            `yaml:"issuer"`
+	ClientID         string            `yaml:"clientId"`
+	ClientSecret     string            `yaml:"clientSecret"`
+	RedirectURL      string            `yaml:"redirectUrl"`
+	Scopes           []string          `yaml:"scopes"`
+	Domains          []string          `yaml:"domains"`
+	UnverifiedEmails bool              `yaml:"unverifiedEmails"`
+	Groups           []string          `yaml:"groups"`
+	GroupsClaim      string            `yaml:"groupsClaim"`
+	GroupProviders   map[string]string `yaml:"groupProviders"`
+	SessionSecret    string            `yaml:"sessionSecret"`
+	SessionTTL       string            `yaml:"sessionTtl"`
+}
+
+// ListenDelegation represents the structure of the config listen delegation field
+type ListenDelegation struct {
+	File   string `yaml:"file"`
+	Secret string `yaml:"secret"`
+}
+
+// ListenRateLimit represents the structure of the config listen rate limit field
+type ListenRateLimit struct {
+	Rate   int64  `yaml:"rate"`
+	Burst  int64  `yaml:"burst"`
+	Header string `yaml:"header"`
+	Hops   int64  `yaml:"hops"`
+}
+
+// ListenReadiness represents the structure of the config listen readiness field
+type ListenReadiness struct {
+	TTL      string `yaml:"ttl"`
+	Timeout  string `yaml:"timeout"`
+	Required string `yaml:"required"`
+}
+
 // Assets represents the structure of the config assets field
 type Assets struct {
 	GATrackingCode string     `yaml:"gaTrackingCode"`
@@ -74,6 +127,45 @@ type Provider struct {
 	Repo     string `yaml:"repo"`
 	Query    string `yaml:"query"`
 	Rewrite  string `yaml:"rewrite"`
+
+	Models       []string          `yaml:"models"`
+	Boards       []string          `yaml:"boards"`
+	Organization string            `yaml:"organization"`
+	Archived     bool              `yaml:"archived"`
+	Spaces       []string          `yaml:"spaces"`
+	Topics       []string          `yaml:"topics"`
+	Wildcard     string            `yaml:"wildcard"`
+	Filter       string            `yaml:"filter"`
+	Mode         string            `yaml:"mode"`
+	Group        string            `yaml:"group"`
+	Paths        []string          `yaml:"paths"`
+	Excludes     []string          `yaml:"excludes"`
+	MaxSize      int64             `yaml:"maxSize"`
+	Git          bool              `yaml:"git"`
+	CommitURL    string            `yaml:"commitUrl"`
+	Template     string            `yaml:"template"`
+	Fields       map[string]string `yaml:"fields"`
+	Kubeconfig   string            `yaml:"kubeconfig"`
+	Context      string            `yaml:"context"`
+	Namespaces   []string          `yaml:"namespaces"`
+	Dashboard    string            `yaml:"dashboard"`
+	Feeds        []string          `yaml:"feeds"`
+	Refresh      string            `yaml:"refresh"`
+	Snapshot     string            `yaml:"snapshot"`
+	Driver       string            `yaml:"driver"`
+	DSN          string            `yaml:"dsn"`
+	ReadOnly     bool              `yaml:"readOnly"`
+	Base         string            `yaml:"base"`
+	Profile      string            `yaml:"profile"`
+	Teams        []string          `yaml:"teams"`
+	Channels     []string          `yaml:"channels"`
+	Site         string            `yaml:"site"`
+	Team         string            `yaml:"team"`
+	Builds       int64             `yaml:"builds"`
+	Repositories []string          `yaml:"repositories"`
+	Delegated    bool              `yaml:"delegated"`
+	Fallback     bool              `yaml:"fallback"`
+	Concurrency  int64             `yaml:"concurrency"`
 }
 
 // Load loads the configuration from the given file
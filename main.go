@@ -89,6 +89,7 @@ func main() {
 	} else if cli.SubCommand == "listen" {
 		// Listen
 		search.Init(config)
+		api.Version = version
 		api.Init(config)
 		assets.Init(config)
 		api.Listen()
@@ -0,0 +1,250 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package metrics provides Prometheus metrics functionality
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the default histogram buckets in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var (
+	mu      sync.Mutex
+	metrics = make(map[string]metric)
+)
+
+// metric is the interface that must be implemented by a metric
+type metric interface {
+	write(w io.Writer)
+}
+
+// register registers the given metric
+func register(name string, m metric) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := metrics[name]; ok {
+		panic("metric " + name + " is already registered")
+	}
+	metrics[name] = m
+}
+
+// Write writes the registered metrics in the Prometheus text format
+func Write(w io.Writer) {
+	mu.Lock()
+	names := make([]string, 0, len(metrics))
+	for n := range metrics {
+		names = append(names, n)
+	}
+	mu.Unlock()
+	sort.Strings(names)
+	for _, n := range names {
+		mu.Lock()
+		m := metrics[n]
+		mu.Unlock()
+		m.write(w)
+	}
+}
+
+// Counter represents a counter or a gauge with labels
+type Counter struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+	mu     sync.Mutex
+	values map[string]*value
+}
+
+// value represents the value of a label set
+type value struct {
+	labels []string
+	value  float64
+}
+
+// NewCounter creates and registers a counter
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, typ: "counter", labels: labels, values: make(map[string]*value)}
+	register(name, c)
+	return c
+}
+
+// NewGauge creates and registers a gauge
+func NewGauge(name, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, typ: "gauge", labels: labels, values: make(map[string]*value)}
+	register(name, c)
+	return c
+}
+
+// Add adds the given value to the metric of the given label values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	k := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cv, ok := c.values[k]
+	if !ok {
+		cv = &value{labels: labelValues}
+		c.values[k] = cv
+	}
+	cv.value += v
+}
+
+// Inc increments the metric of the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Set sets the metric of the given label values. It's meant for the gauges.
+func (c *Counter) Set(v float64, labelValues ...string) {
+	k := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[k] = &value{labels: labelValues, value: v}
+}
+
+// write writes the metric
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", c.name, escapeHelp(c.help), c.name, c.typ)
+	for _, k := range sortedKeys(c.values) {
+		v := c.values[k]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labelString(c.labels, v.labels, "", ""), formatFloat(v.value))
+	}
+}
+
+// Histogram represents a histogram with labels
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+	labels  []string
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+// histogramValue represents the value of a label set
+type histogramValue struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates and registers a histogram.
+// DefaultBuckets are used when there is no any bucket.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	b := append([]float64{}, buckets...)
+	sort.Float64s(b)
+	h := &Histogram{name: name, help: help, buckets: b, labels: labels, values: make(map[string]*histogramValue)}
+	register(name, h)
+	return h
+}
+
+// Observe observes the given value for the given label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	k := strings.Join(labelValues, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hv, ok := h.values[k]
+	if !ok {
+		hv = &histogramValue{labels: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.values[k] = hv
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			hv.counts[i]++
+		}
+	}
+	hv.count++
+	hv.sum += v
+}
+
+// write writes the metric
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, escapeHelp(h.help), h.name)
+	for _, k := range sortedKeys(h.values) {
+		v := h.values[k]
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelString(h.labels, v.labels, "le", formatFloat(b)), v.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labelString(h.labels, v.labels, "le", "+Inf"), v.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labelString(h.labels, v.labels, "", ""), formatFloat(v.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labelString(h.labels, v.labels, "", ""), v.count)
+	}
+}
+
+// sortedKeys returns the sorted keys of the given map
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch v := m.(type) {
+	case map[string]*value:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]*histogramValue:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// labelString returns the label set of the given names and values (i.e. {a="1",b="2"})
+func labelString(names, values []string, extraName, extraValue string) string {
+	var l []string
+	for i, n := range names {
+		var v string
+		if i < len(values) {
+			v = values[i]
+		}
+		l = append(l, n+"=\""+escapeLabel(v)+"\"")
+	}
+	if extraName != "" {
+		l = append(l, extraName+"=\""+extraValue+"\"")
+	}
+	if len(l) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(l, ",") + "}"
+}
+
+// escapeLabel escapes the given label value
+func escapeLabel(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(s)
+}
+
+// escapeHelp escapes the given help text
+func escapeHelp(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(s)
+}
+
+// formatFloat formats the given value for the text format
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,71 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	c := NewCounter("test_requests_total", "Requests per route and status.", "route", "status")
+	c.Inc("/a", "200")
+	c.Add(2, "/a", "200")
+	c.Inc("/b\"\\\n", "500")
+	g := NewGauge("test_up", "Up \\ down\nstatus.")
+	g.Set(1)
+	g.Set(0)
+	h := NewHistogram("test_duration_seconds", "Duration per route.", []float64{1, 0.1}, "route")
+	h.Observe(0.25, "/a")
+	h.Observe(0.5, "/a")
+	h.Observe(2, "/a")
+	h.Observe(0.1, "/b")
+	i := NewGauge("test_inf", "Infinity.", "sign")
+	i.Set(math.Inf(1), "+")
+	i.Set(math.Inf(-1), "-")
+
+	var b bytes.Buffer
+	Write(&b)
+	exp := `# HELP test_duration_seconds Duration per route.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/a",le="0.1"} 0
+test_duration_seconds_bucket{route="/a",le="1"} 2
+test_duration_seconds_bucket{route="/a",le="+Inf"} 3
+test_duration_seconds_sum{route="/a"} 2.75
+test_duration_seconds_count{route="/a"} 3
+test_duration_seconds_bucket{route="/b",le="0.1"} 1
+test_duration_seconds_bucket{route="/b",le="1"} 1
+test_duration_seconds_bucket{route="/b",le="+Inf"} 1
+test_duration_seconds_sum{route="/b"} 0.1
+test_duration_seconds_count{route="/b"} 1
+# HELP test_inf Infinity.
+# TYPE test_inf gauge
+test_inf{sign="+"} +Inf
+test_inf{sign="-"} -Inf
+# HELP test_requests_total Requests per route and status.
+# TYPE test_requests_total counter
+test_requests_total{route="/a",status="200"} 3
+test_requests_total{route="/b\"\\\n",status="500"} 1
+# HELP test_up Up \\ down\nstatus.
+# TYPE test_up gauge
+test_up 0
+`
+	if b.String() != exp {
+		t.Errorf("expected\n%s\ngot\n%s", exp, b.String())
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	NewCounter("test_duplicate_total", "Duplicate.")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for the duplicate metric")
+		}
+	}()
+	NewGauge("test_duplicate_total", "Duplicate.")
+}
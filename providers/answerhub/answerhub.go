@@ -41,6 +41,16 @@ func Register(config map[string]interface{}, f func(interface{}) error) {
 	password, _ := config["Password"].(string)
 	query, _ := config["Query"].(string)
 	rewrite, _ := config["Rewrite"].(string)
+	models, _ := config["Models"].([]string)
+	if len(models) == 0 {
+		models = []string{"question", "answer", "comment"}
+	}
+	topics, _ := config["Topics"].([]string)
+	spaces, _ := config["Spaces"].([]string)
+	wildcard, _ := config["Wildcard"].(string)
+	if wildcard == "" {
+		wildcard = "suffix"
+	}
 
 	p := Provider{
 		provider: "answerhub",
@@ -52,6 +62,10 @@ func Register(config map[string]interface{}, f func(interface{}) error) {
 		password: password,
 		query:    query,
 		rewrite:  rewrite,
+		models:   models,
+		topics:   topics,
+		spaces:   spaces,
+		wildcard: wildcard,
 	}
 	if p.url != "" {
 		p.enabled = true
@@ -73,6 +87,10 @@ type Provider struct {
 	password string
 	query    string
 	rewrite  string
+	models   []string
+	topics   []string
+	spaces   []string
+	wildcard string
 }
 
 // Search makes a search
@@ -89,7 +107,15 @@ func (provider *Provider) Search(ctx context.Context, args map[string]interface{
 	}
 	keyword, ok := args["keyword"].(string)
 
-	u := fmt.Sprintf("%s/services/v2/node.json?page=%d&pageSize=%d&q=%s*", provider.url, page, limit, url.QueryEscape(keyword))
+	// The content types are filtered by AnswerHub so the pages aren't short
+	u := fmt.Sprintf("%s/services/v2/node.json?page=%d&pageSize=%d&q=%s", provider.url, page, limit, url.QueryEscape(provider.wildcardKeyword(keyword)))
+	u += fmt.Sprintf("&type=%s", url.QueryEscape(strings.Join(provider.models, ",")))
+	if len(provider.topics) > 0 {
+		u += fmt.Sprintf("&topics=%s", url.QueryEscape(strings.Join(provider.topics, ",")))
+	}
+	if len(provider.spaces) > 0 {
+		u += fmt.Sprintf("&spaces=%s", url.QueryEscape(strings.Join(provider.spaces, ",")))
+	}
 	if provider.query != "" {
 		u += fmt.Sprintf("%s", provider.query)
 	}
@@ -117,19 +143,68 @@ func (provider *Provider) Search(ctx context.Context, args map[string]interface{
 		return nil, errors.New("failed to unmarshal JSON data. Error: " + err.Error())
 	}
 	for _, v := range sr.List {
-		d := strings.TrimSpace(v.Body)
-		if len(d) > 255 {
-			d = d[0:252] + "..."
-		} else if len(d) == 0 {
-			if v.Author.Realname != "" {
-				d = "Asked by " + v.Author.Realname
+		t := v.Type
+		if t == "" {
+			t = "question"
+		}
+
+		var author string
+		if v.Author != nil {
+			author = v.Author.Realname
+			if author == "" {
+				author = v.Author.Username
+			}
+		}
+
+		// Link answers and comments back to their question
+		var l, tt, d string
+		switch t {
+		case "answer", "comment":
+			qid := v.OriginalParentID
+			if qid == 0 {
+				qid = v.ParentID
+			}
+			l = fmt.Sprintf("%s/questions/%d/?childToView=%d#%s-%d", provider.url, qid, v.ID, t, v.ID)
+			tt = v.Title
+			if tt == "" {
+				tt = fmt.Sprintf("question #%d", qid)
+			}
+			if t == "answer" {
+				if v.Marked {
+					tt = "Accepted answer to " + tt
+				} else {
+					tt = "Answer to " + tt
+				}
 			} else {
-				d = "Asked by " + v.Author.Username
+				tt = "Comment on " + tt
+			}
+		default:
+			l = fmt.Sprintf("%s/questions/%d/", provider.url, v.ID)
+			tt = v.Title
+			if v.AcceptedAnswerID != 0 {
+				tt += " [accepted]"
 			}
 		}
+
+		d = strings.TrimSpace(v.Body)
+		if len(d) == 0 {
+			switch t {
+			case "answer":
+				d = "Answered by " + author
+			case "comment":
+				d = "Commented by " + author
+			default:
+				d = "Asked by " + author
+			}
+		}
+		d = fmt.Sprintf("(%d votes) %s", v.Score, d)
+		if len(d) > 255 {
+			d = d[0:252] + "..."
+		}
+
 		ri := map[string]interface{}{
-			"Link":        fmt.Sprintf("%s/questions/%d/", provider.url, v.ID),
-			"Title":       v.Title,
+			"Link":        l,
+			"Title":       tt,
 			"Description": d,
 			"Date":        time.Unix(0, v.CreationDate*int64(time.Millisecond)),
 		}
@@ -139,6 +214,27 @@ func (provider *Provider) Search(ctx context.Context, args map[string]interface{
 	return results, err
 }
 
+// wildcardKeyword applies the wildcard configuration to the given keyword
+func (provider *Provider) wildcardKeyword(keyword string) string {
+	switch provider.wildcard {
+	case "none":
+		return keyword
+	case "words":
+		w := strings.Fields(keyword)
+		for i, v := range w {
+			if !strings.HasSuffix(v, "*") && !strings.HasSuffix(v, "\"") {
+				w[i] = v + "*"
+			}
+		}
+		return strings.Join(w, " ")
+	default:
+		if strings.HasSuffix(keyword, "*") || strings.HasSuffix(keyword, "\"") {
+			return keyword
+		}
+		return keyword + "*"
+	}
+}
+
 // SearchResult represents the structure of the search result
 type SearchResult struct {
 	List []*SRList `json:"list"`
@@ -146,11 +242,17 @@ type SearchResult struct {
 
 // SRList represents the structure of the search result list
 type SRList struct {
-	ID           int        `json:"id"`
-	Title        string     `json:"title"`
-	Body         string     `json:"body"`
-	Author       *SRLAuthor `json:"author"`
-	CreationDate int64      `json:"creationDate"`
+	ID               int        `json:"id"`
+	Type             string     `json:"type"`
+	Title            string     `json:"title"`
+	Body             string     `json:"body"`
+	Author           *SRLAuthor `json:"author"`
+	CreationDate     int64      `json:"creationDate"`
+	Score            int        `json:"score"`
+	ParentID         int        `json:"parentId"`
+	OriginalParentID int        `json:"originalParentId"`
+	AcceptedAnswerID int        `json:"acceptedAnswerId"`
+	Marked           bool       `json:"marked"`
 }
 
 // SRLAuthor represents the structure of the search result list author field
@@ -0,0 +1,73 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package chat implements the common helpers of the chat providers
+package chat
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	slackLinkRe = regexp.MustCompile(`<([^<>|]+)(?:\|([^<>]*))?>`)
+	mdLinkRe    = regexp.MustCompile(`!?\[([^\]]*)\]\(([^)\s]+)\)`)
+	codeBlockRe = regexp.MustCompile("(?s)```[a-zA-Z0-9]*\\n?(.*?)```")
+	markRe      = regexp.MustCompile("(^|[\\s(])(\\*\\*|__|~~|[*_~`])([^\\s*_~`](?:[^\\n]*?[^\\s*_~`])?)(\\*\\*|__|~~|[*_~`])([\\s).,:;!?]|$)")
+)
+
+// Render converts the given Slack or Markdown message markup to plain text.
+// Links are replaced by their labels, mentions by their names and the
+// emphasis and code markers are removed.
+func Render(text string) string {
+
+	// Slack links, mentions and commands (i.e. <https://...|label>, <@U123|name>, <#C123|name>, <!here>)
+	text = slackLinkRe.ReplaceAllStringFunc(text, func(s string) string {
+		m := slackLinkRe.FindStringSubmatch(s)
+		v, label := m[1], m[2]
+		switch {
+		case strings.HasPrefix(v, "@"):
+			if label != "" {
+				return "@" + label
+			}
+			return v
+		case strings.HasPrefix(v, "#"):
+			if label != "" {
+				return "#" + label
+			}
+			return v
+		case strings.HasPrefix(v, "!"):
+			if label != "" {
+				return label
+			}
+			return "@" + strings.SplitN(v[1:], "^", 2)[0]
+		case label != "":
+			return label
+		}
+		return strings.TrimPrefix(v, "mailto:")
+	})
+
+	// Markdown links, code blocks and emphasis
+	text = mdLinkRe.ReplaceAllString(text, "$1")
+	text = codeBlockRe.ReplaceAllString(text, "$1")
+	for i := 0; i < 3; i++ {
+		r := markRe.ReplaceAllStringFunc(text, func(s string) string {
+			m := markRe.FindStringSubmatch(s)
+			if m[2] != m[4] {
+				return s
+			}
+			return m[1] + m[3] + m[5]
+		})
+		if r == text {
+			break
+		}
+		text = r
+	}
+
+	text = html.UnescapeString(text)
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,26 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package chat
+
+import (
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		text, expected string
+	}{
+		{"see <https://example.com|the docs> <@U123|jane>", "see the docs @jane"},
+		{"<!here> **bold** and `code`", "@here bold and code"},
+		{"[link](https://example.com) &amp; more", "link & more"},
+	}
+	for _, tt := range tests {
+		if r := Render(tt.text); r != tt.expected {
+			t.Errorf("Render(%q): expected %q, got %q", tt.text, tt.expected, r)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package confluence implements Confluence provider
+package confluence
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yieldbot/ferret/providers/text"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+const (
+	hlStart = "@@@hl@@@"
+	hlEnd   = "@@@endhl@@@"
+)
+
+// Register registers the provider
+func Register(config map[string]interface{}, f func(interface{}) error) {
+
+	name, ok := config["Name"].(string)
+	if name == "" || !ok {
+		name = "confluence"
+	}
+	title, ok := config["Title"].(string)
+	if title == "" || !ok {
+		title = "Confluence"
+	}
+	priority, ok := config["Priority"].(int64)
+	if priority == 0 || !ok {
+		priority = 900
+	}
+	url, _ := config["URL"].(string)
+	username, _ := config["Username"].(string)
+	password, _ := config["Password"].(string)
+	token, _ := config["Token"].(string)
+	spaces, _ := config["Spaces"].([]string)
+	query, _ := config["Query"].(string)
+	rewrite, _ := config["Rewrite"].(string)
+
+	p := Provider{
+		provider: "confluence",
+		name:     name,
+		title:    title,
+		priority: priority,
+		url:      strings.TrimSuffix(url, "/"),
+		username: username,
+		password: password,
+		token:    token,
+		spaces:   spaces,
+		query:    query,
+		rewrite:  rewrite,
+	}
+	if p.url != "" {
+		p.enabled = true
+	}
+
+	if err := f(&p); err != nil {
+		panic(err)
+	}
+}
+
+// Provider represents the provider
+type Provider struct {
+	provider string
+	enabled  bool
+	name     string
+	title    string
+	priority int64
+	url      string
+	username string
+	password string
+	token    string
+	spaces   []string
+	query    string
+	rewrite  string
+}
+
+// Search makes a search
+func (provider *Provider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+
+	results := []map[string]interface{}{}
+	page, ok := args["page"].(int)
+	if page < 1 || !ok {
+		page = 1
+	}
+	limit, ok := args["limit"].(int)
+	if limit < 1 || !ok {
+		limit = 10
+	}
+	keyword, ok := args["keyword"].(string)
+
+	u := fmt.Sprintf("%s/rest/api/search?start=%d&limit=%d&excerpt=highlight&cql=%s", provider.url, (page-1)*limit, limit, url.QueryEscape(provider.cql(keyword)))
+	if provider.query != "" {
+		u += fmt.Sprintf("%s", provider.query)
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, errors.New("failed to prepare request. Error: " + err.Error())
+	}
+	if provider.username != "" && provider.token != "" {
+		req.SetBasicAuth(provider.username, provider.token)
+	} else if provider.username != "" || provider.password != "" {
+		req.SetBasicAuth(provider.username, provider.password)
+	} else if provider.token != "" {
+		req.Header.Set("Authorization", "Bearer "+provider.token)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return nil, err
+	} else if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	var sr SearchResult
+	if err := json.Unmarshal(data, &sr); err != nil {
+		return nil, errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+	}
+	base := provider.url
+	if sr.Links != nil && sr.Links.Base != "" {
+		base = strings.TrimSuffix(sr.Links.Base, "/")
+	}
+	for _, v := range sr.Results {
+		tt := html.UnescapeString(strings.NewReplacer(hlStart, "", hlEnd, "").Replace(v.Title))
+		if v.Container != nil && v.Container.Title != "" {
+			tt = fmt.Sprintf("%s in %s", tt, v.Container.Title)
+		}
+
+		var t time.Time
+		if ts, err := time.Parse(time.RFC3339, v.LastModified); err == nil {
+			t = ts
+		}
+
+		ri := map[string]interface{}{
+			"Link":        base + v.URL,
+			"Title":       tt,
+			"Description": snippet(v.Excerpt),
+			"Date":        t,
+		}
+		results = append(results, ri)
+	}
+
+	return results, err
+}
+
+// cql returns the CQL query for the given keyword
+func (provider *Provider) cql(keyword string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	q := fmt.Sprintf(`text ~ "%s" AND type = page`, r.Replace(keyword))
+	if len(provider.spaces) > 0 {
+		sl := []string{}
+		for _, v := range provider.spaces {
+			sl = append(sl, fmt.Sprintf(`"%s"`, r.Replace(v)))
+		}
+		q += fmt.Sprintf(" AND space IN (%s)", strings.Join(sl, ","))
+	}
+	return q
+}
+
+// snippet converts the given highlighted excerpt to a plain text snippet
+// which starts around the first highlighted match (i.e. ... keyword ...)
+func snippet(excerpt string) string {
+
+	// Find the first match and remove the highlight markers
+	p := 0
+	if i := strings.Index(excerpt, hlStart); i > 0 {
+		p = len(strings.Join(strings.Fields(html.UnescapeString(excerpt[:i])), " "))
+	}
+	d := strings.NewReplacer(hlStart, "", hlEnd, "").Replace(excerpt)
+	d = strings.Join(strings.Fields(html.UnescapeString(d)), " ")
+	return text.SnippetAt(d, p)
+}
+
+// SearchResult represents the structure of the search result
+type SearchResult struct {
+	Start     int          `json:"start"`
+	Limit     int          `json:"limit"`
+	Size      int          `json:"size"`
+	TotalSize int          `json:"totalSize"`
+	Results   []*SRResults `json:"results"`
+	Links     *SRLinks     `json:"_links"`
+}
+
+// SRResults represents the structure of the search result results
+type SRResults struct {
+	Title        string        `json:"title"`
+	Excerpt      string        `json:"excerpt"`
+	URL          string        `json:"url"`
+	LastModified string        `json:"lastModified"`
+	Container    *SRRContainer `json:"resultGlobalContainer"`
+}
+
+// SRRContainer represents the structure of the search result results container field
+type SRRContainer struct {
+	Title      string `json:"title"`
+	DisplayURL string `json:"displayUrl"`
+}
+
+// SRLinks represents the structure of the search result links field
+type SRLinks struct {
+	Base string `json:"base"`
+}
@@ -0,0 +1,115 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package confluence
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestSearch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/search" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if u, p, _ := r.BasicAuth(); u != "user" || p != "secret" {
+			t.Errorf("unexpected credentials %s:%s", u, p)
+		}
+		q := r.URL.Query()
+		if q.Get("start") != "10" || q.Get("limit") != "10" || q.Get("excerpt") != "highlight" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		if e := `text ~ "deploy" AND type = page AND space IN ("OPS")`; q.Get("cql") != e {
+			t.Errorf("expected cql %s, got %s", e, q.Get("cql"))
+		}
+		fmt.Fprint(w, `{"start":10,"limit":10,"size":1,"totalSize":11,"results":[
+			{"title":"@@@hl@@@Deploy@@@endhl@@@ &amp; rollback","excerpt":"How to @@@hl@@@deploy@@@endhl@@@ the  api","url":"/display/OPS/Deploy","lastModified":"2017-02-01T10:00:00.000Z","resultGlobalContainer":{"title":"Operations"}}
+		],"_links":{"base":"https://wiki.example.com/"}}`)
+	}))
+	defer ts.Close()
+
+	p := Provider{url: ts.URL, username: "user", token: "secret", spaces: []string{"OPS"}}
+	res, err := p.Search(context.Background(), map[string]interface{}{"page": 2, "limit": 10, "keyword": "deploy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(res))
+	}
+	if l := res[0]["Link"]; l != "https://wiki.example.com/display/OPS/Deploy" {
+		t.Errorf("unexpected link %v", l)
+	}
+	if tt := res[0]["Title"]; tt != "Deploy & rollback in Operations" {
+		t.Errorf("unexpected title %v", tt)
+	}
+	if d := res[0]["Description"]; d != "How to deploy the api" {
+		t.Errorf("unexpected description %v", d)
+	}
+}
+
+func TestSearchError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"bad cql"}`, http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	p := Provider{url: ts.URL}
+	_, err := p.Search(context.Background(), map[string]interface{}{"page": 1, "limit": 10, "keyword": "deploy"})
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("expected a bad response error, got %v", err)
+	}
+}
+
+func TestCQL(t *testing.T) {
+	tests := []struct {
+		keyword string
+		spaces  []string
+		cql     string
+	}{
+		{"deploy", nil, `text ~ "deploy" AND type = page`},
+		{`a "b" c:\d`, nil, `text ~ "a \"b\" c:\\d" AND type = page`},
+		{"deploy", []string{"OPS", `E"NG`}, `text ~ "deploy" AND type = page AND space IN ("OPS","E\"NG")`},
+	}
+	for _, tt := range tests {
+		p := Provider{spaces: tt.spaces}
+		if q := p.cql(tt.keyword); q != tt.cql {
+			t.Errorf("cql(%q): expected %s, got %s", tt.keyword, tt.cql, q)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("lorem ipsum ", 30)
+	tests := []struct {
+		excerpt string
+		prefix  string
+		match   string
+	}{
+		{"a @@@hl@@@b@@@endhl@@@ &lt;c&gt;", "a b <c>", "b"},
+		{"@@@hl@@@match@@@endhl@@@ " + long, "match lorem", "match"},
+		{long + "the @@@hl@@@match@@@endhl@@@ " + long, "...", "match"},
+		{long + "&amp;\n\n@@@hl@@@match@@@endhl@@@ " + long, "...", "& match"},
+		{long + long, "lorem", ""},
+	}
+	for _, tt := range tests {
+		s := snippet(tt.excerpt)
+		if len(s) > 255 {
+			t.Errorf("snippet(%q): expected at most 255 bytes, got %d", tt.excerpt, len(s))
+		}
+		if strings.Contains(s, hlStart) || strings.Contains(s, hlEnd) {
+			t.Errorf("snippet(%q): expected no highlight markers, got %q", tt.excerpt, s)
+		}
+		if !strings.HasPrefix(s, tt.prefix) || !strings.Contains(s, tt.match) {
+			t.Errorf("snippet(%q): expected prefix %q and match %q, got %q", tt.excerpt, tt.prefix, tt.match, s)
+		}
+	}
+}
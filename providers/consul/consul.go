@@ -158,6 +158,23 @@ func (provider *Provider) Search(ctx context.Context, args map[string]interface{
 	return results, err
 }
 
+// Probe checks the reachability of the provider by the datacenters endpoint
+func (provider *Provider) Probe(ctx context.Context) error {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/v1/catalog/datacenters", provider.url), nil)
+	if err != nil {
+		return errors.New("failed to prepare request. Error: " + err.Error())
+	}
+	res, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+	}
+	return nil
+}
+
 // datacenter gets the list of the datacenters
 func (provider *Provider) datacenter() ([]string, error) {
 
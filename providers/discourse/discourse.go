@@ -0,0 +1,364 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package discourse implements Discourse provider
+package discourse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+const (
+	// pageSize is the fixed page size of the Discourse search API
+	pageSize = 50
+	// maxPages is the maximum number of the search pages which are fetched for a search
+	maxPages = 10
+)
+
+// Register registers the provider
+func Register(config map[string]interface{}, f func(interface{}) error) {
+
+	name, ok := config["Name"].(string)
+	if name == "" || !ok {
+		name = "discourse"
+	}
+	title, ok := config["Title"].(string)
+	if title == "" || !ok {
+		title = "Discourse"
+	}
+	priority, ok := config["Priority"].(int64)
+	if priority == 0 || !ok {
+		priority = 1000
+	}
+	u, _ := config["URL"].(string)
+	username, _ := config["Username"].(string)
+	token, _ := config["Token"].(string)
+	query, _ := config["Query"].(string)
+	rewrite, _ := config["Rewrite"].(string)
+	models, _ := config["Models"].([]string)
+	if len(models) == 0 {
+		models = []string{"topic", "post"}
+	}
+	topics, _ := config["Topics"].([]string)
+	spaces, _ := config["Spaces"].([]string)
+
+	p := Provider{
+		provider: "discourse",
+		name:     name,
+		title:    title,
+		priority: priority,
+		url:      strings.TrimSuffix(u, "/"),
+		username: username,
+		token:    token,
+		query:    query,
+		rewrite:  rewrite,
+		models:   models,
+		tags:     topics,
+		spaces:   spaces,
+	}
+	if p.url != "" {
+		p.enabled = true
+	}
+	if err := f(&p); err != nil {
+		panic(err)
+	}
+}
+
+// Provider represents the provider
+type Provider struct {
+	provider string
+	enabled  bool
+	name     string
+	title    string
+	priority int64
+	url      string
+	username string
+	token    string
+	query    string
+	rewrite  string
+	models   []string
+	tags     []string
+	spaces   []string
+
+	mu         sync.Mutex
+	categories map[int]*SRCategory
+}
+
+// Search makes a search
+func (provider *Provider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+
+	results := []map[string]interface{}{}
+	page, ok := args["page"].(int)
+	if page < 1 || !ok {
+		page = 1
+	}
+	limit, ok := args["limit"].(int)
+	if limit < 1 || !ok {
+		limit = 10
+	}
+	keyword, ok := args["keyword"].(string)
+
+	q := keyword
+	if len(provider.spaces) == 1 {
+		q += " category:" + provider.spaces[0]
+	}
+	if len(provider.tags) > 0 {
+		q += " tags:" + strings.Join(provider.tags, ",")
+	}
+	if provider.query != "" {
+		q += " " + provider.query
+	}
+
+	categories, err := provider.categoryList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The page size of the Discourse search is fixed and the results are
+	// filtered by the models and categories so the results are collected
+	// from the first page until the requested page is filled
+	for dp := 1; dp <= maxPages && len(results) < page*limit; dp++ {
+		var sr SearchResult
+		u := fmt.Sprintf("%s/search.json?q=%s&page=%d", provider.url, url.QueryEscape(q), dp)
+		if err := provider.get(ctx, u, &sr); err != nil {
+			return nil, err
+		}
+
+		topics := map[int]*SRTopics{}
+		for _, v := range sr.Topics {
+			topics[v.ID] = v
+		}
+		for _, v := range sr.Posts {
+			t := topics[v.TopicID]
+			if t == nil {
+				t = &SRTopics{ID: v.TopicID, Title: fmt.Sprintf("topic #%d", v.TopicID)}
+			}
+			if ri := provider.result(v, t, categories); ri != nil {
+				results = append(results, ri)
+			}
+		}
+		if len(sr.Posts) < pageSize || sr.GroupedSearchResult == nil || !sr.GroupedSearchResult.MoreFullPageResults {
+			break
+		}
+	}
+
+	if len(results) > 0 {
+		var l, h = 0, limit
+		if page > 1 {
+			h = (page * limit)
+			l = h - limit
+		}
+		if l > len(results) {
+			l = len(results)
+		}
+		if h > len(results) {
+			h = len(results)
+		}
+		results = results[l:h]
+	}
+
+	return results, nil
+}
+
+// result returns the search result of the given post.
+// It returns nil if the post is filtered out.
+func (provider *Provider) result(v *SRPosts, t *SRTopics, categories map[int]*SRCategory) map[string]interface{} {
+	model := "post"
+	if v.PostNumber <= 1 {
+		model = "topic"
+	}
+	if !provider.hasModel(model) {
+		return nil
+	}
+	c := categories[t.CategoryID]
+	if len(provider.spaces) > 0 && (c == nil || !contains(provider.spaces, c.Slug)) {
+		return nil
+	}
+
+	var tt string
+	switch {
+	case model == "topic":
+		tt = t.Title
+		if t.HasAcceptedAnswer {
+			tt += " [accepted]"
+		}
+	case v.ID == t.AcceptedAnswerPostID:
+		tt = "Accepted answer to " + t.Title
+	default:
+		tt = "Reply to " + t.Title
+	}
+
+	var dl []string
+	if model == "topic" {
+		dl = append(dl, fmt.Sprintf("(%d likes, %d replies)", t.LikeCount, t.ReplyCount))
+	} else {
+		dl = append(dl, fmt.Sprintf("(%d likes)", v.LikeCount))
+	}
+	if c != nil {
+		dl = append(dl, "["+c.Name+"]")
+	}
+	for _, tag := range t.Tags {
+		dl = append(dl, "#"+tag)
+	}
+	b := strings.TrimSpace(html.UnescapeString(v.Blurb))
+	if b == "" {
+		b = "Posted by " + v.Username
+	}
+	d := strings.Join(append(dl, b), " ")
+	if len(d) > 255 {
+		d = d[0:252] + "..."
+	}
+
+	slug := t.Slug
+	if slug == "" {
+		slug = "-"
+	}
+	return map[string]interface{}{
+		"Link":        fmt.Sprintf("%s/t/%s/%d/%d", provider.url, slug, t.ID, v.PostNumber),
+		"Title":       tt,
+		"Description": d,
+		"Date":        v.CreatedAt,
+	}
+}
+
+// categoryList returns the categories by using a cache
+func (provider *Provider) categoryList(ctx context.Context) (map[int]*SRCategory, error) {
+	provider.mu.Lock()
+	categories := provider.categories
+	provider.mu.Unlock()
+	if categories != nil {
+		return categories, nil
+	}
+
+	var cr CategoryResult
+	if err := provider.get(ctx, provider.url+"/categories.json?include_subcategories=true", &cr); err != nil {
+		return nil, err
+	}
+	categories = map[int]*SRCategory{}
+	if cr.CategoryList != nil {
+		for _, v := range cr.CategoryList.Categories {
+			categories[v.ID] = v
+			for _, sc := range v.SubcategoryList {
+				categories[sc.ID] = sc
+			}
+		}
+	}
+	provider.mu.Lock()
+	provider.categories = categories
+	provider.mu.Unlock()
+	return categories, nil
+}
+
+// get makes a GET request to the given URL and unmarshals the response into v
+func (provider *Provider) get(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return errors.New("failed to prepare request. Error: " + err.Error())
+	}
+	req.Header.Set("Accept", "application/json")
+	if provider.token != "" {
+		req.Header.Set("Api-Key", provider.token)
+		req.Header.Set("Api-Username", provider.username)
+	}
+
+	res, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return err
+	} else if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+	}
+	return nil
+}
+
+// hasModel checks whether the given model is enabled or not
+func (provider *Provider) hasModel(model string) bool {
+	return contains(provider.models, model)
+}
+
+// contains checks whether the given list contains the value or not
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchResult represents the structure of the search result
+type SearchResult struct {
+	Posts               []*SRPosts             `json:"posts"`
+	Topics              []*SRTopics            `json:"topics"`
+	GroupedSearchResult *SRGroupedSearchResult `json:"grouped_search_result"`
+}
+
+// SRPosts represents the structure of the search result posts
+type SRPosts struct {
+	ID         int       `json:"id"`
+	Username   string    `json:"username"`
+	CreatedAt  time.Time `json:"created_at"`
+	LikeCount  int       `json:"like_count"`
+	Blurb      string    `json:"blurb"`
+	PostNumber int       `json:"post_number"`
+	TopicID    int       `json:"topic_id"`
+}
+
+// SRTopics represents the structure of the search result topics
+type SRTopics struct {
+	ID                   int      `json:"id"`
+	Title                string   `json:"title"`
+	Slug                 string   `json:"slug"`
+	CategoryID           int      `json:"category_id"`
+	Tags                 []string `json:"tags"`
+	ReplyCount           int      `json:"reply_count"`
+	LikeCount            int      `json:"like_count"`
+	HasAcceptedAnswer    bool     `json:"has_accepted_answer"`
+	AcceptedAnswerPostID int      `json:"accepted_answer_post_id"`
+}
+
+// SRGroupedSearchResult represents the structure of the search result grouped_search_result field
+type SRGroupedSearchResult struct {
+	MoreFullPageResults bool `json:"more_full_page_results"`
+}
+
+// CategoryResult represents the structure of the category list
+type CategoryResult struct {
+	CategoryList *CRCategoryList `json:"category_list"`
+}
+
+// CRCategoryList represents the structure of the category list categories
+type CRCategoryList struct {
+	Categories []*SRCategory `json:"categories"`
+}
+
+// SRCategory represents the structure of a category
+type SRCategory struct {
+	ID              int           `json:"id"`
+	Name            string        `json:"name"`
+	Slug            string        `json:"slug"`
+	SubcategoryList []*SRCategory `json:"subcategory_list"`
+}
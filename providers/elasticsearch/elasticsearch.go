@@ -0,0 +1,277 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package elasticsearch implements Elasticsearch and OpenSearch provider
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// defaultTemplate is the query DSL template which is used when there is no template
+const defaultTemplate = `{"query":{"query_string":{"query":"{keyword}","default_operator":"AND"}}}`
+
+// Register registers the provider
+func Register(config map[string]interface{}, f func(interface{}) error) {
+
+	name, ok := config["Name"].(string)
+	if name == "" || !ok {
+		name = "elasticsearch"
+	}
+	title, ok := config["Title"].(string)
+	if title == "" || !ok {
+		title = "Elasticsearch"
+	}
+	priority, ok := config["Priority"].(int64)
+	if priority == 0 || !ok {
+		priority = 300
+	}
+	url, _ := config["URL"].(string)
+	username, _ := config["Username"].(string)
+	password, _ := config["Password"].(string)
+	token, _ := config["Token"].(string)
+	template, _ := config["Template"].(string)
+	if template == "" {
+		template = defaultTemplate
+	}
+	fields := map[string]string{
+		"link":        "url",
+		"title":       "title",
+		"description": "description",
+		"date":        "date",
+	}
+	if fm, ok := config["Fields"].(map[string]string); ok {
+		for k, v := range fm {
+			fields[k] = v
+		}
+	}
+	query, _ := config["Query"].(string)
+	rewrite, _ := config["Rewrite"].(string)
+
+	p := Provider{
+		provider: "elasticsearch",
+		name:     name,
+		title:    title,
+		priority: priority,
+		url:      strings.TrimSuffix(url, "/"),
+		username: username,
+		password: password,
+		token:    token,
+		template: template,
+		fields:   fields,
+		query:    query,
+		rewrite:  rewrite,
+	}
+	if p.url != "" {
+		p.enabled = true
+	}
+
+	if err := f(&p); err != nil {
+		panic(err)
+	}
+}
+
+// Provider represents the provider
+type Provider struct {
+	provider string
+	enabled  bool
+	name     string
+	title    string
+	priority int64
+	url      string
+	username string
+	password string
+	token    string
+	template string
+	fields   map[string]string
+	query    string
+	rewrite  string
+}
+
+// Search makes a search
+func (provider *Provider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+
+	results := []map[string]interface{}{}
+	page, ok := args["page"].(int)
+	if page < 1 || !ok {
+		page = 1
+	}
+	limit, ok := args["limit"].(int)
+	if limit < 1 || !ok {
+		limit = 10
+	}
+	keyword, ok := args["keyword"].(string)
+
+	body, err := provider.body(keyword, page, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	u := fmt.Sprintf("%s/_search", provider.url)
+	if provider.query != "" {
+		u += fmt.Sprintf("%s", provider.query)
+	}
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.New("failed to prepare request. Error: " + err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if provider.token != "" {
+		req.Header.Set("Authorization", "ApiKey "+provider.token)
+	} else if provider.username != "" || provider.password != "" {
+		req.SetBasicAuth(provider.username, provider.password)
+	}
+
+	res, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return nil, err
+	} else if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	var sr SearchResult
+	if err := json.Unmarshal(data, &sr); err != nil {
+		return nil, errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+	}
+	if sr.Hits == nil {
+		return results, nil
+	}
+
+	// The total is a number before Elasticsearch 7 and an object after
+	var tn int
+	var to struct {
+		Value int `json:"value"`
+	}
+	if err := json.Unmarshal(sr.Hits.Total, &tn); err == nil {
+		args["total"] = tn
+	} else if err := json.Unmarshal(sr.Hits.Total, &to); err == nil {
+		args["total"] = to.Value
+	}
+
+	for _, v := range sr.Hits.Hits {
+		l, _ := field(v.Source, provider.fields["link"]).(string)
+		tt, _ := field(v.Source, provider.fields["title"]).(string)
+		if tt == "" {
+			tt = v.ID
+		}
+
+		var d string
+		if hl, ok := v.Highlight[provider.fields["description"]]; ok && len(hl) > 0 {
+			d = strings.Join(hl, "...")
+			d = strings.NewReplacer("<em>", "", "</em>", "").Replace(d)
+		} else {
+			d, _ = field(v.Source, provider.fields["description"]).(string)
+		}
+		d = strings.Join(strings.Fields(d), " ")
+		if len(d) > 255 {
+			d = d[0:252] + "..."
+		}
+
+		ri := map[string]interface{}{
+			"Link":        l,
+			"Title":       tt,
+			"Description": d,
+			"Date":        parseDate(field(v.Source, provider.fields["date"])),
+		}
+		results = append(results, ri)
+	}
+
+	return results, nil
+}
+
+// body returns the request body by using the query DSL template
+func (provider *Provider) body(keyword string, page, limit int) ([]byte, error) {
+
+	// Escape the keyword for JSON strings
+	k, err := json.Marshal(keyword)
+	if err != nil {
+		return nil, err
+	}
+	t := strings.Replace(provider.template, "{keyword}", string(k[1:len(k)-1]), -1)
+
+	var b map[string]interface{}
+	if err := json.Unmarshal([]byte(t), &b); err != nil {
+		return nil, errors.New("invalid query template. Error: " + err.Error())
+	}
+	b["from"] = (page - 1) * limit
+	b["size"] = limit
+	if _, ok := b["highlight"]; !ok && provider.fields["description"] != "" {
+		b["highlight"] = map[string]interface{}{
+			"fields": map[string]interface{}{
+				provider.fields["description"]: map[string]interface{}{},
+			},
+		}
+	}
+
+	return json.Marshal(b)
+}
+
+// field returns the value of the given dot separated field path
+func field(source map[string]interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	var v interface{} = source
+	for _, p := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[p]
+	}
+	return v
+}
+
+// parseDate parses the given date value
+func parseDate(v interface{}) time.Time {
+	var t time.Time
+	switch d := v.(type) {
+	case string:
+		for _, l := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+			if ts, err := time.Parse(l, d); err == nil {
+				return ts
+			}
+		}
+	case float64:
+		// Epoch milliseconds
+		t = time.Unix(0, int64(d)*int64(time.Millisecond))
+	}
+	return t
+}
+
+// SearchResult represents the structure of the search result
+type SearchResult struct {
+	Took int     `json:"took"`
+	Hits *SRHits `json:"hits"`
+}
+
+// SRHits represents the structure of the search result hits
+type SRHits struct {
+	Total json.RawMessage `json:"total"`
+	Hits  []*SRHHits      `json:"hits"`
+}
+
+// SRHHits represents the structure of the search result hits hits field
+type SRHHits struct {
+	Index     string                 `json:"_index"`
+	ID        string                 `json:"_id"`
+	Source    map[string]interface{} `json:"_source"`
+	Highlight map[string][]string    `json:"highlight"`
+}
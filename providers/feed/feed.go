@@ -0,0 +1,492 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package feed implements RSS and Atom feed provider
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+var (
+	tagRe       = regexp.MustCompile(`<[^>]*>`)
+	dateLayouts = []string{
+		time.RFC1123Z,
+		time.RFC1123,
+		time.RFC3339,
+		time.RFC822Z,
+		time.RFC822,
+		"Mon, 2 Jan 2006 15:04:05 -0700",
+		"Mon, 2 Jan 2006 15:04:05 MST",
+		"2006-01-02T15:04:05Z0700",
+		"2006-01-02",
+	}
+)
+
+// Register registers the provider
+func Register(config map[string]interface{}, f func(interface{}) error) {
+
+	name, ok := config["Name"].(string)
+	if name == "" || !ok {
+		name = "feed"
+	}
+	title, ok := config["Title"].(string)
+	if title == "" || !ok {
+		title = "Feed"
+	}
+	priority, ok := config["Priority"].(int64)
+	if priority == 0 || !ok {
+		priority = 400
+	}
+	feeds, _ := config["Feeds"].([]string)
+	refresh := 15 * time.Minute
+	if r, ok := config["Refresh"].(string); ok && r != "" {
+		d, err := time.ParseDuration(r)
+		if err != nil {
+			panic("invalid feed refresh interval: " + r)
+		}
+		refresh = d
+	}
+	snapshot, _ := config["Snapshot"].(string)
+	query, _ := config["Query"].(string)
+	rewrite, _ := config["Rewrite"].(string)
+
+	p := Provider{
+		provider: "feed",
+		name:     name,
+		title:    title,
+		priority: priority,
+		feeds:    feeds,
+		refresh:  refresh,
+		snapshot: snapshot,
+		query:    query,
+		rewrite:  rewrite,
+		entries:  map[string][]*Entry{},
+		fetched:  map[string]time.Time{},
+		fetching: map[string]bool{},
+		failures: map[string]int{},
+		retry:    map[string]time.Time{},
+		errs:     map[string]string{},
+	}
+	if len(p.feeds) > 0 {
+		p.enabled = true
+	}
+	p.loadSnapshot()
+
+	if err := f(&p); err != nil {
+		panic(err)
+	}
+}
+
+// Provider represents the provider
+type Provider struct {
+	provider string
+	enabled  bool
+	name     string
+	title    string
+	priority int64
+	feeds    []string
+	refresh  time.Duration
+	snapshot string
+	query    string
+	rewrite  string
+
+	mu       sync.Mutex
+	entries  map[string][]*Entry
+	fetched  map[string]time.Time
+	fetching map[string]bool
+	failures map[string]int
+	retry    map[string]time.Time
+	errs     map[string]string
+}
+
+// Entry represents a feed entry
+type Entry struct {
+	Feed    string    `json:"feed"`
+	Title   string    `json:"title"`
+	Link    string    `json:"link"`
+	Summary string    `json:"summary"`
+	Date    time.Time `json:"date"`
+}
+
+// Search makes a search
+func (provider *Provider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+
+	results := []map[string]interface{}{}
+	page, ok := args["page"].(int)
+	if page < 1 || !ok {
+		page = 1
+	}
+	limit, ok := args["limit"].(int)
+	if limit < 1 || !ok {
+		limit = 10
+	}
+	keyword, ok := args["keyword"].(string)
+
+	entries, updated, warnings, err := provider.update(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if updated {
+		args["cache"] = "miss"
+	} else {
+		args["cache"] = "hit"
+	}
+	if len(warnings) > 0 {
+		args["warnings"] = warnings
+	}
+
+	words := strings.Fields(strings.ToLower(keyword))
+	var matches []*Entry
+	for _, v := range entries {
+		s := strings.ToLower(v.Title + " " + v.Summary)
+		ok := true
+		for _, w := range words {
+			if !strings.Contains(s, w) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matches = append(matches, v)
+		}
+	}
+	sort.Sort(byDate(matches))
+
+	for _, v := range matches {
+		d := v.Summary
+		if len(d) > 255 {
+			d = d[0:252] + "..."
+		}
+		ri := map[string]interface{}{
+			"Link":        v.Link,
+			"Title":       v.Title,
+			"Description": d,
+			"Date":        v.Date,
+		}
+		if v.Feed != "" {
+			ri["Title"] = fmt.Sprintf("%s: %s", v.Feed, v.Title)
+		}
+		results = append(results, ri)
+	}
+
+	if len(results) > 0 {
+		var l, h = 0, limit
+		if page > 1 {
+			h = (page * limit)
+			l = h - limit
+		}
+		if l > len(results) {
+			l = len(results)
+		}
+		if h > len(results) {
+			h = len(results)
+		}
+		results = results[l:h]
+	}
+
+	return results, nil
+}
+
+// update refreshes the expired feeds and returns all the entries, whether any
+// feed is refreshed or not and the fetch errors.
+// The feeds are fetched without holding the lock so a slow feed doesn't block
+// the other searches which use the current entries meanwhile. Each feed is fetched
+// by its own timeout which is shorter than the search so a hanging feed doesn't fail
+// the search. The previous entries of a feed are kept if it can't be refreshed
+// and the failed (or timed out) feeds are retried after a backoff.
+func (provider *Provider) update(ctx context.Context) ([]*Entry, bool, []string, error) {
+
+	// Claim the expired feeds
+	provider.mu.Lock()
+	now := time.Now()
+	var stale []string
+	for _, u := range provider.feeds {
+		if provider.fetching[u] || now.Sub(provider.fetched[u]) < provider.refresh || now.Before(provider.retry[u]) {
+			continue
+		}
+		provider.fetching[u] = true
+		stale = append(stale, u)
+	}
+	provider.mu.Unlock()
+
+	// Fetch
+	fetched := make([][]*Entry, len(stale))
+	fetchErrs := make([]error, len(stale))
+	timeout := fetchTimeout(ctx)
+	var wg sync.WaitGroup
+	for i, u := range stale {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			fctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			fetched[i], fetchErrs[i] = fetch(fctx, u)
+			if fetchErrs[i] != nil && fctx.Err() == context.DeadlineExceeded {
+				fetchErrs[i] = errors.New("timeout")
+			}
+		}(i, u)
+	}
+	wg.Wait()
+
+	// Swap the entries in
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	updated := false
+	for i, u := range stale {
+		delete(provider.fetching, u)
+		if err := fetchErrs[i]; err != nil {
+			// The feed isn't blamed if the search is over before its own timeout
+			if ctx.Err() != nil {
+				continue
+			}
+			provider.failures[u]++
+			provider.retry[u] = time.Now().Add(backoff(provider.failures[u], provider.refresh))
+			provider.errs[u] = err.Error()
+			continue
+		}
+		provider.entries[u] = fetched[i]
+		provider.fetched[u] = time.Now()
+		delete(provider.failures, u)
+		delete(provider.retry, u)
+		delete(provider.errs, u)
+		updated = true
+	}
+	if updated {
+		provider.saveSnapshot()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, false, nil, err
+	}
+
+	var errs []string
+	entries := []*Entry{}
+	for _, u := range provider.feeds {
+		entries = append(entries, provider.entries[u]...)
+		if e, ok := provider.errs[u]; ok {
+			errs = append(errs, u+": "+e)
+		}
+	}
+	if len(entries) == 0 && len(errs) > 0 {
+		return nil, false, nil, errors.New("failed to fetch feeds. Error: " + strings.Join(errs, ", "))
+	}
+	return entries, updated, errs, nil
+}
+
+// fetchTimeout returns the timeout of the feed fetches by the given search context.
+// It's the half of the remaining search time (10 seconds at most) so the
+// search can still return the cached entries when a feed hangs.
+func fetchTimeout(ctx context.Context) time.Duration {
+	d := 10 * time.Second
+	if dl, ok := ctx.Deadline(); ok {
+		if r := time.Until(dl) / 2; r < d {
+			d = r
+		}
+	}
+	return d
+}
+
+// backoff returns the retry delay of a feed by the given number of the consecutive
+// failures. It starts from 30 seconds, doubles by each failure and is capped by the refresh interval.
+func backoff(failures int, refresh time.Duration) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < failures && d < refresh; i++ {
+		d *= 2
+	}
+	if d > refresh {
+		d = refresh
+	}
+	return d
+}
+
+// loadSnapshot loads the entries from the snapshot file
+func (provider *Provider) loadSnapshot() {
+	if provider.snapshot == "" {
+		return
+	}
+	data, err := ioutil.ReadFile(provider.snapshot)
+	if err != nil {
+		return
+	}
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return
+	}
+	for k, v := range s.Entries {
+		provider.entries[k] = v
+		provider.fetched[k] = s.Fetched[k]
+	}
+}
+
+// saveSnapshot saves the entries into the snapshot file
+func (provider *Provider) saveSnapshot() {
+	if provider.snapshot == "" {
+		return
+	}
+	data, err := json.Marshal(snapshot{Entries: provider.entries, Fetched: provider.fetched})
+	if err != nil {
+		return
+	}
+	ioutil.WriteFile(provider.snapshot, data, 0600)
+}
+
+// snapshot represents the structure of the snapshot file
+type snapshot struct {
+	Entries map[string][]*Entry  `json:"entries"`
+	Fetched map[string]time.Time `json:"fetched"`
+}
+
+// fetch fetches and parses the given feed
+func fetch(ctx context.Context, u string) ([]*Entry, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, errors.New("failed to prepare request. Error: " + err.Error())
+	}
+
+	res, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return nil, err
+	} else if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return nil, errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	var fr SearchResult
+	if err := xml.Unmarshal(data, &fr); err != nil {
+		return nil, errors.New("failed to unmarshal XML data. Error: " + err.Error())
+	}
+
+	entries := []*Entry{}
+	ft := strings.TrimSpace(fr.Title)
+	if fr.Channel != nil {
+		ft = strings.TrimSpace(fr.Channel.Title)
+		fr.Items = append(fr.Channel.Items, fr.Items...)
+	}
+
+	// RSS
+	for _, v := range fr.Items {
+		d := v.PubDate
+		if d == "" {
+			d = v.Date
+		}
+		entries = append(entries, &Entry{
+			Feed:    ft,
+			Title:   text(v.Title),
+			Link:    strings.TrimSpace(v.Link),
+			Summary: text(v.Description),
+			Date:    parseDate(d),
+		})
+	}
+
+	// Atom
+	for _, v := range fr.Entries {
+		var l string
+		for _, vl := range v.Links {
+			if vl.Rel == "" || vl.Rel == "alternate" {
+				l = vl.Href
+				break
+			}
+		}
+		s := v.Summary
+		if s == "" {
+			s = v.Content
+		}
+		d := v.Updated
+		if d == "" {
+			d = v.Published
+		}
+		entries = append(entries, &Entry{
+			Feed:    ft,
+			Title:   text(v.Title),
+			Link:    l,
+			Summary: text(s),
+			Date:    parseDate(d),
+		})
+	}
+
+	return entries, nil
+}
+
+// text converts the given HTML content to plain text
+func text(s string) string {
+	s = html.UnescapeString(tagRe.ReplaceAllString(s, " "))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// parseDate parses the given feed date
+func parseDate(d string) time.Time {
+	d = strings.TrimSpace(d)
+	for _, l := range dateLayouts {
+		if t, err := time.Parse(l, d); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// byDate implements sort.Interface for sorting the entries by date, newest first
+type byDate []*Entry
+
+func (e byDate) Len() int           { return len(e) }
+func (e byDate) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e byDate) Less(i, j int) bool { return e[i].Date.After(e[j].Date) }
+
+// SearchResult represents the structure of an RSS, RDF or Atom feed
+type SearchResult struct {
+	Title   string       `xml:"title"`
+	Channel *SRChannel   `xml:"channel"`
+	Items   []*SRItems   `xml:"item"`
+	Entries []*SREntries `xml:"entry"`
+}
+
+// SRChannel represents the structure of the RSS channel
+type SRChannel struct {
+	Title string     `xml:"title"`
+	Items []*SRItems `xml:"item"`
+}
+
+// SRItems represents the structure of the RSS items
+type SRItems struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+// SREntries represents the structure of the Atom entries
+type SREntries struct {
+	Title     string      `xml:"title"`
+	Links     []*SRELinks `xml:"link"`
+	Summary   string      `xml:"summary"`
+	Content   string      `xml:"content"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published"`
+}
+
+// SRELinks represents the structure of the Atom entry links
+type SRELinks struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
@@ -0,0 +1,187 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package feed
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const rss = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Blog</title>
+<item><title>Hello &lt;b&gt;world&lt;/b&gt;</title><link>https://example.com/1</link><description>First post</description><pubDate>Mon, 02 Jan 2017 15:04:05 +0000</pubDate></item>
+</channel></rss>`
+
+const atom = `<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Notes</title>
+<entry><title>Hello notes</title><link rel="alternate" href="https://example.com/2"/><summary>Second</summary><updated>2017-01-03T10:00:00Z</updated></entry>
+</feed>`
+
+// newProvider registers a provider by the given config for the tests
+func newProvider(t *testing.T, config map[string]interface{}) *Provider {
+	var p *Provider
+	Register(config, func(v interface{}) error {
+		p = v.(*Provider)
+		return nil
+	})
+	return p
+}
+
+// feedServer serves the given feed and counts the requests
+func feedServer(body string, status int, count *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(count, 1)
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		refresh  time.Duration
+		delay    time.Duration
+	}{
+		{1, 15 * time.Minute, 30 * time.Second},
+		{2, 15 * time.Minute, time.Minute},
+		{4, 15 * time.Minute, 4 * time.Minute},
+		{10, 15 * time.Minute, 15 * time.Minute},
+		{1, 10 * time.Second, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if d := backoff(tt.failures, tt.refresh); d != tt.delay {
+			t.Errorf("backoff(%d, %s): expected %s, got %s", tt.failures, tt.refresh, tt.delay, d)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	var n1, n2 int32
+	s1 := feedServer(rss, http.StatusOK, &n1)
+	defer s1.Close()
+	s2 := feedServer(atom, http.StatusOK, &n2)
+	defer s2.Close()
+
+	p := newProvider(t, map[string]interface{}{"Feeds": []string{s1.URL, s2.URL}, "Refresh": "1h"})
+	args := map[string]interface{}{"page": 1, "limit": 10, "keyword": "hello"}
+	res, err := p.Search(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0]["Title"] != "Notes: Hello notes" || res[1]["Title"] != "Blog: Hello world" || res[1]["Description"] != "First post" {
+		t.Errorf("unexpected results %v", res)
+	}
+	if args["cache"] != "miss" {
+		t.Errorf("expected a cache miss, got %v", args["cache"])
+	}
+
+	// The fresh feeds are not fetched again
+	args = map[string]interface{}{"page": 1, "limit": 10, "keyword": "world"}
+	if res, err = p.Search(context.Background(), args); err != nil || len(res) != 1 {
+		t.Errorf("unexpected results %v %v", res, err)
+	}
+	if args["cache"] != "hit" || atomic.LoadInt32(&n1) != 1 || atomic.LoadInt32(&n2) != 1 {
+		t.Errorf("expected a cache hit, got %v (%d, %d requests)", args["cache"], atomic.LoadInt32(&n1), atomic.LoadInt32(&n2))
+	}
+}
+
+func TestPartialFailure(t *testing.T) {
+	var n1, n2 int32
+	s1 := feedServer(rss, http.StatusOK, &n1)
+	defer s1.Close()
+	s2 := feedServer("", http.StatusInternalServerError, &n2)
+	defer s2.Close()
+
+	p := newProvider(t, map[string]interface{}{"Feeds": []string{s1.URL, s2.URL}, "Refresh": "1h"})
+	for i := 0; i < 2; i++ {
+		args := map[string]interface{}{"page": 1, "limit": 10, "keyword": "hello"}
+		res, err := p.Search(context.Background(), args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 {
+			t.Errorf("unexpected results %v", res)
+		}
+		if w, _ := args["warnings"].([]string); len(w) != 1 || w[0] != s2.URL+": bad response: 500" {
+			t.Errorf("unexpected warnings %v", args["warnings"])
+		}
+	}
+
+	// The failed feed is retried after the backoff
+	if atomic.LoadInt32(&n2) != 1 || p.failures[s2.URL] != 1 || !p.retry[s2.URL].After(time.Now()) {
+		t.Errorf("unexpected retry state: %d requests, %d failures, retry at %s", atomic.LoadInt32(&n2), p.failures[s2.URL], p.retry[s2.URL])
+	}
+
+	// The search fails only if there is no any entry
+	p = newProvider(t, map[string]interface{}{"Feeds": []string{s2.URL}})
+	if _, err := p.Search(context.Background(), map[string]interface{}{"keyword": "hello"}); err == nil || !strings.Contains(err.Error(), "bad response: 500") {
+		t.Errorf("expected a fetch error, got %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	var n1, n2 int32
+	s1 := feedServer(rss, http.StatusOK, &n1)
+	defer s1.Close()
+	s2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n2, 1)
+		<-r.Context().Done()
+	}))
+	defer s2.Close()
+
+	// A hanging feed times out before the search and the other entries are returned
+	p := newProvider(t, map[string]interface{}{"Feeds": []string{s1.URL, s2.URL}, "Refresh": "1h"})
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		args := map[string]interface{}{"page": 1, "limit": 10, "keyword": "hello"}
+		res, err := p.Search(ctx, args)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 {
+			t.Errorf("unexpected results %v", res)
+		}
+		if w, _ := args["warnings"].([]string); len(w) != 1 || w[0] != s2.URL+": timeout" {
+			t.Errorf("unexpected warnings %v", args["warnings"])
+		}
+	}
+	if atomic.LoadInt32(&n2) != 1 || p.failures[s2.URL] != 1 {
+		t.Errorf("expected the timed out feed to back off, got %d requests and %d failures", atomic.LoadInt32(&n2), p.failures[s2.URL])
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	var n int32
+	s := feedServer(rss, http.StatusOK, &n)
+	config := map[string]interface{}{"Feeds": []string{s.URL}, "Refresh": "1h", "Snapshot": filepath.Join(t.TempDir(), "feed.json")}
+
+	p := newProvider(t, config)
+	if _, err := p.Search(context.Background(), map[string]interface{}{"keyword": "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// The entries are loaded from the snapshot without fetching the feed
+	p = newProvider(t, config)
+	args := map[string]interface{}{"page": 1, "limit": 10, "keyword": "hello"}
+	res, err := p.Search(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0]["Link"] != "https://example.com/1" || args["cache"] != "hit" || atomic.LoadInt32(&n) != 1 {
+		t.Errorf("unexpected results %v (cache %v, %d requests)", res, args["cache"], atomic.LoadInt32(&n))
+	}
+}
@@ -0,0 +1,445 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package files implements local filesystem and git repository provider
+package files
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// errLimit is used for stopping the walk when enough results are found
+var errLimit = errors.New("limit reached")
+
+// Register registers the provider
+func Register(config map[string]interface{}, f func(interface{}) error) {
+
+	name, ok := config["Name"].(string)
+	if name == "" || !ok {
+		name = "files"
+	}
+	title, ok := config["Title"].(string)
+	if title == "" || !ok {
+		title = "Files"
+	}
+	priority, ok := config["Priority"].(int64)
+	if priority == 0 || !ok {
+		priority = 50
+	}
+	url, _ := config["URL"].(string)
+	commitURL, _ := config["CommitURL"].(string)
+	paths, _ := config["Paths"].([]string)
+	excludes, _ := config["Excludes"].([]string)
+	maxSize, ok := config["MaxSize"].(int64)
+	if maxSize <= 0 || !ok {
+		maxSize = 1024 * 1024
+	}
+	mode, _ := config["Mode"].(string)
+	if mode == "" {
+		mode = "text"
+	}
+	if mode != "text" && mode != "regex" {
+		panic("invalid files mode: " + mode)
+	}
+	git, _ := config["Git"].(bool)
+	query, _ := config["Query"].(string)
+	rewrite, _ := config["Rewrite"].(string)
+
+	p := Provider{
+		provider:  "files",
+		name:      name,
+		title:     title,
+		priority:  priority,
+		url:       url,
+		commitURL: commitURL,
+		maxSize:   maxSize,
+		mode:      mode,
+		git:       git,
+		query:     query,
+		rewrite:   rewrite,
+	}
+	for _, v := range paths {
+		r, err := filepath.Abs(v)
+		if err != nil {
+			panic(err)
+		}
+		p.roots = append(p.roots, &root{
+			path:     r,
+			excludes: parseExcludes(append(readExcludes(filepath.Join(r, ".gitignore")), excludes...)),
+		})
+	}
+	if len(p.roots) > 0 {
+		p.enabled = true
+	}
+
+	if err := f(&p); err != nil {
+		panic(err)
+	}
+}
+
+// Provider represents the provider
+type Provider struct {
+	provider  string
+	enabled   bool
+	name      string
+	title     string
+	priority  int64
+	url       string
+	commitURL string
+	roots     []*root
+	maxSize   int64
+	mode      string
+	git       bool
+	query     string
+	rewrite   string
+}
+
+// root represents a root path and its exclude patterns
+type root struct {
+	path     string
+	excludes []*exclude
+}
+
+// Search makes a search
+func (provider *Provider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+
+	results := []map[string]interface{}{}
+	page, ok := args["page"].(int)
+	if page < 1 || !ok {
+		page = 1
+	}
+	limit, ok := args["limit"].(int)
+	if limit < 1 || !ok {
+		limit = 10
+	}
+	keyword, ok := args["keyword"].(string)
+
+	match, err := provider.matcher(keyword)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the results until the requested page is filled
+	max := page * limit
+	for _, r := range provider.roots {
+		if len(results) >= max {
+			break
+		}
+		nested := map[string][]*exclude{}
+		err := filepath.Walk(r.path, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(r.path, p)
+			rel = filepath.ToSlash(rel)
+			if rel == "." {
+				return nil
+			}
+			if fi.IsDir() {
+				if fi.Name() == ".git" || r.excluded(nested, rel, true) {
+					return filepath.SkipDir
+				}
+				if el := parseExcludes(readExcludes(filepath.Join(p, ".gitignore"))); len(el) > 0 {
+					nested[rel] = el
+				}
+				return nil
+			}
+			if !fi.Mode().IsRegular() || fi.Size() > provider.maxSize || r.excluded(nested, rel, false) {
+				return nil
+			}
+			for _, ri := range provider.searchFile(r, p, rel, match) {
+				results = append(results, ri)
+				if len(results) >= max {
+					return errLimit
+				}
+			}
+			return nil
+		})
+		if err != nil && err != errLimit {
+			return nil, err
+		}
+	}
+
+	// Commit messages
+	if provider.git {
+		for _, r := range provider.roots {
+			if len(results) >= max {
+				break
+			}
+			cl, err := provider.searchLog(ctx, r, keyword, max-len(results))
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, cl...)
+		}
+	}
+
+	if len(results) > 0 {
+		var l, h = (page - 1) * limit, max
+		if l > len(results) {
+			l = len(results)
+		}
+		if h > len(results) {
+			h = len(results)
+		}
+		results = results[l:h]
+	}
+
+	return results, nil
+}
+
+// matcher returns the line matcher for the given keyword
+func (provider *Provider) matcher(keyword string) (func(string) bool, error) {
+	if provider.mode == "regex" {
+		re, err := regexp.Compile(keyword)
+		if err != nil {
+			return nil, errors.New("invalid regular expression. Error: " + err.Error())
+		}
+		return re.MatchString, nil
+	}
+	k := strings.ToLower(keyword)
+	return func(s string) bool {
+		return strings.Contains(strings.ToLower(s), k)
+	}, nil
+}
+
+// searchFile searches the given file line by line
+func (provider *Provider) searchFile(r *root, p, rel string, match func(string) bool) []map[string]interface{} {
+	results := []map[string]interface{}{}
+
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return results
+	}
+	// Skip binary files
+	h := data
+	if len(h) > 512 {
+		h = h[:512]
+	}
+	if bytes.IndexByte(h, 0) >= 0 {
+		return results
+	}
+
+	var t time.Time
+	if fi, err := os.Stat(p); err == nil {
+		t = fi.ModTime()
+	}
+
+	var lines []string
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(make([]byte, 64*1024), len(data)+1)
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	for i, v := range lines {
+		if !match(v) {
+			continue
+		}
+
+		// Context snippet
+		var sl []string
+		for j := i - 1; j <= i+1; j++ {
+			if j >= 0 && j < len(lines) {
+				if l := strings.Join(strings.Fields(lines[j]), " "); l != "" {
+					sl = append(sl, l)
+				}
+			}
+		}
+		d := strings.Join(sl, " ... ")
+		if len(d) > 255 {
+			d = d[0:252] + "..."
+		}
+
+		ri := map[string]interface{}{
+			"Link":        provider.fileLink(r, p, rel, i+1),
+			"Title":       fmt.Sprintf("%s/%s:%d", filepath.Base(r.path), rel, i+1),
+			"Description": d,
+			"Date":        t,
+		}
+		results = append(results, ri)
+	}
+
+	return results
+}
+
+// searchLog searches the git log messages of the given root
+func (provider *Provider) searchLog(ctx context.Context, r *root, keyword string, max int) ([]map[string]interface{}, error) {
+	results := []map[string]interface{}{}
+	if fi, err := os.Stat(filepath.Join(r.path, ".git")); err != nil || !fi.IsDir() {
+		return results, nil
+	}
+
+	ga := []string{"-C", r.path, "log", "--regexp-ignore-case", "--max-count=" + strconv.Itoa(max), "--format=%H%x1f%an%x1f%at%x1f%s", "--grep=" + keyword}
+	if provider.mode == "regex" {
+		ga = append(ga, "--extended-regexp")
+	} else {
+		ga = append(ga, "--fixed-strings")
+	}
+	out, err := exec.CommandContext(ctx, "git", ga...).Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, errors.New("failed to search git log. Error: " + err.Error())
+	}
+	for _, v := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		c := strings.Split(v, "\x1f")
+		if len(c) != 4 {
+			continue
+		}
+
+		var t time.Time
+		if ts, err := strconv.ParseInt(c[2], 10, 64); err == nil {
+			t = time.Unix(ts, 0)
+		}
+
+		ri := map[string]interface{}{
+			"Link":        provider.commitLink(r, c[0]),
+			"Title":       fmt.Sprintf("%s@%s: %s", filepath.Base(r.path), c[0][:7], c[3]),
+			"Description": "Committed by " + c[1],
+			"Date":        t,
+		}
+		results = append(results, ri)
+	}
+
+	return results, nil
+}
+
+// fileLink returns the link of the given file line.
+// The URL template supports `{root}`, `{path}` and `{line}` placeholders.
+func (provider *Provider) fileLink(r *root, p, rel string, line int) string {
+	if provider.url == "" {
+		return "file://" + filepath.ToSlash(p)
+	}
+	return strings.NewReplacer("{root}", filepath.Base(r.path), "{path}", rel, "{line}", strconv.Itoa(line)).Replace(provider.url)
+}
+
+// commitLink returns the link of the given commit.
+// The commit URL template supports `{root}` and `{commit}` placeholders.
+func (provider *Provider) commitLink(r *root, commit string) string {
+	if provider.commitURL == "" {
+		return "file://" + filepath.ToSlash(r.path)
+	}
+	return strings.NewReplacer("{root}", filepath.Base(r.path), "{commit}", commit).Replace(provider.commitURL)
+}
+
+// exclude represents a .gitignore-style exclude pattern
+type exclude struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// readExcludes reads the exclude patterns from the given file
+func readExcludes(p string) []string {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(data), "\n")
+}
+
+// parseExcludes parses the given .gitignore-style patterns
+func parseExcludes(patterns []string) []*exclude {
+	el := []*exclude{}
+	for _, v := range patterns {
+		v = strings.TrimSpace(v)
+		if v == "" || strings.HasPrefix(v, "#") {
+			continue
+		}
+		e := exclude{}
+		if strings.HasPrefix(v, "!") {
+			e.negate = true
+			v = v[1:]
+		}
+		if strings.HasSuffix(v, "/") {
+			e.dirOnly = true
+			v = strings.TrimSuffix(v, "/")
+		}
+
+		// Patterns without a slash match at any depth
+		prefix := "^(?:.*/)?"
+		if strings.Contains(v, "/") {
+			prefix = "^"
+			v = strings.TrimPrefix(v, "/")
+		}
+
+		var b bytes.Buffer
+		for i := 0; i < len(v); i++ {
+			switch c := v[i]; c {
+			case '*':
+				if i+2 < len(v) && v[i+1] == '*' && v[i+2] == '/' {
+					b.WriteString("(?:.*/)?")
+					i += 2
+				} else if i+1 < len(v) && v[i+1] == '*' {
+					b.WriteString(".*")
+					i++
+				} else {
+					b.WriteString("[^/]*")
+				}
+			case '?':
+				b.WriteString("[^/]")
+			default:
+				b.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		}
+		re, err := regexp.Compile(prefix + b.String() + "$")
+		if err != nil {
+			continue
+		}
+		e.re = re
+		el = append(el, &e)
+	}
+	return el
+}
+
+// excluded checks whether the given relative path is excluded or not by the
+// exclude patterns of the root and the given .gitignore patterns of the nested
+// directories. The patterns of the deeper directories take precedence like git does.
+func (r *root) excluded(nested map[string][]*exclude, rel string, dir bool) bool {
+	ex, _ := matchExcludes(r.excludes, rel, dir)
+	for d := path.Dir(rel); d != "."; d = path.Dir(d) {
+		// The deepest matching directory decides
+		if el, ok := nested[d]; ok {
+			if e, ok := matchExcludes(el, strings.TrimPrefix(rel, d+"/"), dir); ok {
+				return e
+			}
+		}
+	}
+	return ex
+}
+
+// matchExcludes checks whether the given relative path is excluded by the given patterns
+// and whether any pattern matches it or not. The last matching pattern decides.
+func matchExcludes(el []*exclude, rel string, dir bool) (bool, bool) {
+	ex, matched := false, false
+	for _, e := range el {
+		if e.dirOnly && !dir {
+			continue
+		}
+		if e.re.MatchString(rel) {
+			ex, matched = !e.negate, true
+		}
+	}
+	return ex, matched
+}
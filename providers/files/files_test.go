@@ -0,0 +1,191 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package files
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// writeFiles writes the given files into the given directory
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for k, v := range files {
+		p := filepath.Join(dir, filepath.FromSlash(k))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(v), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newProvider registers a provider by the given config for the tests
+func newProvider(config map[string]interface{}) *Provider {
+	var p *Provider
+	Register(config, func(v interface{}) error {
+		p = v.(*Provider)
+		return nil
+	})
+	return p
+}
+
+// titles returns the sorted titles of the given results
+func titles(results []map[string]interface{}) []string {
+	var tl []string
+	for _, v := range results {
+		tl = append(tl, v["Title"].(string))
+	}
+	sort.Strings(tl)
+	return tl
+}
+
+func TestExcludes(t *testing.T) {
+	el := parseExcludes([]string{"# comment", "", "*.log", "!keep.log", "build/", "/top.txt", "docs/*.md", "a/**/z", "fil?.go"})
+	r := &root{excludes: el}
+	tests := []struct {
+		rel      string
+		dir      bool
+		excluded bool
+	}{
+		{"x.log", false, true},
+		{"a/b/x.log", false, true},
+		{"a/keep.log", false, false},
+		{"build", true, true},
+		{"a/build", true, true},
+		{"build", false, false},
+		{"top.txt", false, true},
+		{"a/top.txt", false, false},
+		{"docs/a.md", false, true},
+		{"docs/a/b.md", false, false},
+		{"a/z", false, true},
+		{"a/b/c/z", false, true},
+		{"file.go", false, true},
+		{"files.go", false, false},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if ex := r.excluded(nil, tt.rel, tt.dir); ex != tt.excluded {
+			t.Errorf("excluded(%q, %t): expected %t, got %t", tt.rel, tt.dir, tt.excluded, ex)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".gitignore":          "*.log\nvendor/\n",
+		"main.go":             "package main\n\n// Needle in the main\nfunc main() {}\n",
+		"app.log":             "needle\n",
+		"vendor/lib.go":       "// needle\n",
+		"sub/.gitignore":      "/local.txt\n!important.log\n",
+		"sub/local.txt":       "needle\n",
+		"sub/deep/local.txt":  "needle\n",
+		"sub/important.log":   "needle\n",
+		"sub/deep/.gitignore": "*.txt\n",
+		"other/local.txt":     "needle\n",
+		"bin.dat":             "needle\x00",
+		"big.txt":             "needle " + strings.Repeat("x", 100) + "\n",
+	})
+	base := filepath.Base(dir)
+
+	tests := []struct {
+		mode    string
+		keyword string
+		titles  []string
+	}{
+		// The nested .gitignore files are respected and the deeper ones take precedence
+		{"text", "NEEDLE", []string{base + "/main.go:3", base + "/other/local.txt:1", base + "/sub/important.log:1"}},
+		{"regex", `^// Needle`, []string{base + "/main.go:3"}},
+		{"regex", `func \w+\(\)`, []string{base + "/main.go:4"}},
+	}
+	for _, tt := range tests {
+		p := newProvider(map[string]interface{}{"Paths": []string{dir}, "Mode": tt.mode, "MaxSize": int64(100)})
+		res, err := p.Search(context.Background(), map[string]interface{}{"page": 1, "limit": 10, "keyword": tt.keyword})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tl := titles(res); strings.Join(tl, ",") != strings.Join(tt.titles, ",") {
+			t.Errorf("%s %q: expected %v, got %v", tt.mode, tt.keyword, tt.titles, tl)
+		}
+	}
+
+	// Context snippet and link
+	p := newProvider(map[string]interface{}{"Paths": []string{dir}, "URL": "https://example.com/{root}/{path}#L{line}"})
+	res, err := p.Search(context.Background(), map[string]interface{}{"page": 1, "limit": 10, "keyword": "in the main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0]["Link"] != "https://example.com/"+base+"/main.go#L3" || res[0]["Description"] != "// Needle in the main ... func main() {}" {
+		t.Errorf("unexpected results %v", res)
+	}
+
+	// Pagination
+	p = newProvider(map[string]interface{}{"Paths": []string{dir}, "MaxSize": int64(100)})
+	res, err = p.Search(context.Background(), map[string]interface{}{"page": 2, "limit": 2, "keyword": "needle"})
+	if err != nil || len(res) != 1 {
+		t.Errorf("unexpected second page %v %v", res, err)
+	}
+
+	// Invalid regular expression
+	p = newProvider(map[string]interface{}{"Paths": []string{dir}, "Mode": "regex"})
+	if _, err := p.Search(context.Background(), map[string]interface{}{"keyword": "("}); err == nil || !strings.Contains(err.Error(), "invalid regular expression") {
+		t.Errorf("expected a regular expression error, got %v", err)
+	}
+}
+
+func TestSearchLog(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	for _, v := range [][]string{
+		{"init", "-q"},
+		{"commit", "-q", "--allow-empty", "-m", "Fix the needle (a+b)"},
+		{"commit", "-q", "--allow-empty", "-m", "Add the haystack"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=Jane", "-c", "user.email=jane@example.com"}, v...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s", v, out)
+		}
+	}
+	base := filepath.Base(dir)
+
+	tests := []struct {
+		mode    string
+		keyword string
+		count   int
+	}{
+		{"text", "NEEDLE (a+b)", 1},
+		{"text", "a+b", 1},
+		{"regex", "needle|haystack", 2},
+		{"regex", "a+b", 0},
+		{"text", "nothing", 0},
+	}
+	for _, tt := range tests {
+		p := newProvider(map[string]interface{}{"Paths": []string{dir}, "Mode": tt.mode, "Git": true, "CommitURL": "https://example.com/{root}/commit/{commit}"})
+		res, err := p.Search(context.Background(), map[string]interface{}{"page": 1, "limit": 10, "keyword": tt.keyword})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != tt.count {
+			t.Errorf("%s %q: expected %d results, got %v", tt.mode, tt.keyword, tt.count, res)
+			continue
+		}
+		for _, v := range res {
+			if !strings.HasPrefix(v["Title"].(string), base+"@") || !strings.HasPrefix(v["Link"].(string), "https://example.com/"+base+"/commit/") || v["Description"] != "Committed by Jane" {
+				t.Errorf("%s %q: unexpected result %v", tt.mode, tt.keyword, v)
+			}
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"golang.org/x/net/context"
 	"golang.org/x/net/context/ctxhttp"
@@ -41,20 +42,24 @@ func Register(config map[string]interface{}, f func(interface{}) error) {
 	repo, _ := config["Repo"].(string)
 	query, _ := config["Query"].(string)
 	rewrite, _ := config["Rewrite"].(string)
+	delegated, _ := config["Delegated"].(bool)
+	fallback, _ := config["Fallback"].(bool)
 
 	p := Provider{
-		provider: "github",
-		name:     name,
-		title:    title,
-		priority: priority,
-		url:      strings.TrimSuffix(url, "/"),
-		token:    token,
-		username: username,
-		repo:     repo,
-		query:    query,
-		rewrite:  rewrite,
-	}
-	if p.token != "" {
+		provider:  "github",
+		name:      name,
+		title:     title,
+		priority:  priority,
+		url:       strings.TrimSuffix(url, "/"),
+		token:     token,
+		username:  username,
+		repo:      repo,
+		query:     query,
+		rewrite:   rewrite,
+		delegated: delegated,
+		fallback:  fallback,
+	}
+	if p.token != "" || p.delegated {
 		p.enabled = true
 	}
 
@@ -65,17 +70,19 @@ func Register(config map[string]interface{}, f func(interface{}) error) {
 
 // Provider represents the provider
 type Provider struct {
-	provider string
-	enabled  bool
-	name     string
-	title    string
-	priority int64
-	url      string
-	token    string
-	username string
-	repo     string
-	query    string
-	rewrite  string
+	provider  string
+	enabled   bool
+	name      string
+	title     string
+	priority  int64
+	url       string
+	token     string
+	username  string
+	repo      string
+	query     string
+	rewrite   string
+	delegated bool
+	fallback  bool
 }
 
 // Search makes a search
@@ -91,6 +98,10 @@ func (provider *Provider) Search(ctx context.Context, args map[string]interface{
 		limit = 10
 	}
 	keyword, ok := args["keyword"].(string)
+	token := provider.token
+	if t, ok := args["token"].(string); ok && t != "" {
+		token = t
+	}
 
 	u := fmt.Sprintf("%s/search/code?page=%d&per_page=%d&q=%s", provider.url, page, limit, url.QueryEscape(keyword))
 	if provider.repo != "" {
@@ -107,8 +118,8 @@ func (provider *Provider) Search(ctx context.Context, args map[string]interface{
 	if err != nil {
 		return nil, errors.New("failed to prepare request. Error: " + err.Error())
 	}
-	if provider.token != "" {
-		req.Header.Set("Authorization", "token "+provider.token)
+	if token != "" {
+		req.Header.Set("Authorization", "token "+token)
 	}
 	req.Header.Set("Accept", "application/vnd.github.v3.text-match+json")
 
@@ -151,6 +162,38 @@ func (provider *Provider) Search(ctx context.Context, args map[string]interface{
 	return results, err
 }
 
+// Probe checks the reachability of the provider by the rate limit endpoint.
+// It fails when the search rate limit of the shared token is exhausted.
+func (provider *Provider) Probe(ctx context.Context) error {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/rate_limit", provider.url), nil)
+	if err != nil {
+		return errors.New("failed to prepare request. Error: " + err.Error())
+	}
+	if provider.token != "" {
+		req.Header.Set("Authorization", "token "+provider.token)
+	}
+	res, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return err
+	} else if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	var rl RateLimitResult
+	if err := json.Unmarshal(data, &rl); err != nil {
+		return errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+	}
+	if s := rl.Resources.Search; s != nil && s.Limit > 0 && s.Remaining == 0 {
+		return errors.New("search rate limit exceeded until " + time.Unix(s.Reset, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
 // SearchResult represents the structure of the search result
 type SearchResult struct {
 	TotalCount        int        `json:"total_count"`
@@ -177,3 +220,17 @@ type SRIRepository struct {
 type SRITMatches struct {
 	Fragment string `json:"fragment"`
 }
+
+// RateLimitResult represents the structure of the rate limit result
+type RateLimitResult struct {
+	Resources struct {
+		Search *RLRResource `json:"search"`
+	} `json:"resources"`
+}
+
+// RLRResource represents the structure of the rate limit result resource
+type RLRResource struct {
+	Limit     int   `json:"limit"`
+	Remaining int   `json:"remaining"`
+	Reset     int64 `json:"reset"`
+}
@@ -0,0 +1,305 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package gitlab implements GitLab provider
+package gitlab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// maxPerPage is the maximum page size allowed by the GitLab API
+const maxPerPage = 100
+
+// Register registers the provider
+func Register(config map[string]interface{}, f func(interface{}) error) {
+
+	name, ok := config["Name"].(string)
+	if name == "" || !ok {
+		name = "gitlab"
+	}
+	title, ok := config["Title"].(string)
+	if title == "" || !ok {
+		title = "GitLab"
+	}
+	priority, ok := config["Priority"].(int64)
+	if priority == 0 || !ok {
+		priority = 200
+	}
+	url, _ := config["URL"].(string)
+	if url == "" {
+		url = "https://gitlab.com"
+	}
+	token, _ := config["Token"].(string)
+	group, _ := config["Group"].(string)
+	repo, _ := config["Repo"].(string)
+	mode, _ := config["Mode"].(string)
+	if mode == "" {
+		mode = "blobs"
+	}
+	switch mode {
+	case "blobs", "issues", "merge_requests", "projects", "wiki_blobs":
+	default:
+		panic("invalid gitlab mode: " + mode)
+	}
+	query, _ := config["Query"].(string)
+	rewrite, _ := config["Rewrite"].(string)
+
+	p := Provider{
+		provider: "gitlab",
+		name:     name,
+		title:    title,
+		priority: priority,
+		url:      strings.TrimSuffix(url, "/"),
+		token:    token,
+		group:    group,
+		repo:     repo,
+		mode:     mode,
+		query:    query,
+		rewrite:  rewrite,
+		projects: map[int]*SRProject{},
+	}
+	if p.token != "" {
+		p.enabled = true
+	}
+
+	if err := f(&p); err != nil {
+		panic(err)
+	}
+}
+
+// Provider represents the provider
+type Provider struct {
+	provider string
+	enabled  bool
+	name     string
+	title    string
+	priority int64
+	url      string
+	token    string
+	group    string
+	repo     string
+	mode     string
+	query    string
+	rewrite  string
+
+	mu       sync.Mutex
+	projects map[int]*SRProject
+}
+
+// Search makes a search
+func (provider *Provider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+
+	results := []map[string]interface{}{}
+	page, ok := args["page"].(int)
+	if page < 1 || !ok {
+		page = 1
+	}
+	limit, ok := args["limit"].(int)
+	if limit < 1 || !ok {
+		limit = 10
+	}
+	keyword, ok := args["keyword"].(string)
+
+	// Scope
+	u := fmt.Sprintf("%s/api/v4", provider.url)
+	if provider.repo != "" {
+		u += fmt.Sprintf("/projects/%s", url.QueryEscape(provider.repo))
+	} else if provider.group != "" {
+		u += fmt.Sprintf("/groups/%s", url.QueryEscape(provider.group))
+	}
+
+	// GitLab doesn't allow more than 100 items per page so follow the
+	// pagination headers until the limit is reached
+	perPage := limit
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	offset := (page - 1) * limit
+	gp, skip := offset/perPage+1, offset%perPage
+
+	var items []*SRItems
+	for gp > 0 && len(items) < limit {
+		gu := fmt.Sprintf("%s/search?scope=%s&page=%d&per_page=%d&search=%s", u, provider.mode, gp, perPage, url.QueryEscape(keyword))
+		if provider.query != "" {
+			gu += fmt.Sprintf("%s", provider.query)
+		}
+		var sr SearchResult
+		h, err := provider.get(ctx, gu, &sr)
+		if err != nil {
+			return nil, err
+		}
+		if skip > 0 {
+			if skip < len(sr) {
+				sr = sr[skip:]
+			} else {
+				sr = nil
+			}
+			skip = 0
+		}
+		items = append(items, sr...)
+
+		gp, _ = strconv.Atoi(h.Get("X-Next-Page"))
+	}
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	for _, v := range items {
+		var l, tt, d, ts string
+		switch provider.mode {
+		case "blobs", "wiki_blobs":
+			p, err := provider.project(ctx, v.ProjectID)
+			if err != nil {
+				return nil, err
+			}
+			if provider.mode == "wiki_blobs" {
+				l = fmt.Sprintf("%s/-/wikis/%s", p.WebURL, strings.TrimSuffix(v.Path, ".md"))
+			} else {
+				l = fmt.Sprintf("%s/-/blob/%s/%s#L%d", p.WebURL, v.Ref, v.Path, v.Startline)
+			}
+			tt = fmt.Sprintf("%s/%s", p.PathWithNamespace, strings.TrimPrefix(v.Path, "/"))
+			d = v.Data
+		case "projects":
+			l = v.WebURL
+			tt = v.NameWithNamespace
+			d = v.Description
+			ts = v.LastActivityAt
+		default:
+			l = v.WebURL
+			tt = v.Title
+			if v.References != nil && v.References.Full != "" {
+				tt = fmt.Sprintf("%s: %s", v.References.Full, v.Title)
+			}
+			d = v.Description
+			if v.State != "" {
+				d = fmt.Sprintf("[%s] %s", v.State, d)
+			}
+			ts = v.UpdatedAt
+		}
+
+		d = strings.TrimSpace(d)
+		if len(d) > 255 {
+			d = d[0:252] + "..."
+		}
+
+		var t time.Time
+		if ts != "" {
+			if tp, err := time.Parse(time.RFC3339, ts); err == nil {
+				t = tp
+			}
+		}
+
+		ri := map[string]interface{}{
+			"Link":        l,
+			"Title":       tt,
+			"Description": d,
+			"Date":        t,
+		}
+		results = append(results, ri)
+	}
+
+	return results, nil
+}
+
+// project returns the given project by using a cache
+func (provider *Provider) project(ctx context.Context, id int) (*SRProject, error) {
+	provider.mu.Lock()
+	p, ok := provider.projects[id]
+	provider.mu.Unlock()
+	if ok {
+		return p, nil
+	}
+
+	p = &SRProject{}
+	if _, err := provider.get(ctx, fmt.Sprintf("%s/api/v4/projects/%d", provider.url, id), p); err != nil {
+		return nil, err
+	}
+	provider.mu.Lock()
+	provider.projects[id] = p
+	provider.mu.Unlock()
+	return p, nil
+}
+
+// Probe checks the reachability of the provider by the version endpoint
+func (provider *Provider) Probe(ctx context.Context) error {
+	var v map[string]interface{}
+	_, err := provider.get(ctx, fmt.Sprintf("%s/api/v4/version", provider.url), &v)
+	return err
+}
+
+// get makes a GET request to the given URL and unmarshals the response into v
+func (provider *Provider) get(ctx context.Context, u string, v interface{}) (http.Header, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, errors.New("failed to prepare request. Error: " + err.Error())
+	}
+	if provider.token != "" {
+		req.Header.Set("PRIVATE-TOKEN", provider.token)
+	}
+
+	res, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return nil, err
+	} else if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+	}
+	return res.Header, nil
+}
+
+// SearchResult represents the structure of the search result
+type SearchResult []*SRItems
+
+// SRItems represents the structure of the search result items
+type SRItems struct {
+	// blobs and wiki_blobs
+	ProjectID int    `json:"project_id"`
+	Path      string `json:"path"`
+	Ref       string `json:"ref"`
+	Startline int    `json:"startline"`
+	Data      string `json:"data"`
+
+	// issues, merge_requests and projects
+	Title             string         `json:"title"`
+	Description       string         `json:"description"`
+	State             string         `json:"state"`
+	WebURL            string         `json:"web_url"`
+	UpdatedAt         string         `json:"updated_at"`
+	References        *SRIReferences `json:"references"`
+	NameWithNamespace string         `json:"name_with_namespace"`
+	LastActivityAt    string         `json:"last_activity_at"`
+}
+
+// SRIReferences represents the structure of the search result items references field
+type SRIReferences struct {
+	Full string `json:"full"`
+}
+
+// SRProject represents the structure of a project
+type SRProject struct {
+	WebURL            string `json:"web_url"`
+	PathWithNamespace string `json:"path_with_namespace"`
+}
@@ -0,0 +1,420 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package jenkins implements Jenkins provider
+package jenkins
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+const (
+	// maxDepth is the maximum folder depth which is fetched
+	maxDepth = 5
+	// maxLogJobs is the maximum number of the jobs whose build logs are searched
+	maxLogJobs = 20
+)
+
+// Register registers the provider
+func Register(config map[string]interface{}, f func(interface{}) error) {
+
+	name, ok := config["Name"].(string)
+	if name == "" || !ok {
+		name = "jenkins"
+	}
+	title, ok := config["Title"].(string)
+	if title == "" || !ok {
+		title = "Jenkins"
+	}
+	priority, ok := config["Priority"].(int64)
+	if priority == 0 || !ok {
+		priority = 800
+	}
+	u, _ := config["URL"].(string)
+	username, _ := config["Username"].(string)
+	token, _ := config["Token"].(string)
+	paths, _ := config["Paths"].([]string)
+	builds, _ := config["Builds"].(int64)
+	maxSize, ok := config["MaxSize"].(int64)
+	if maxSize <= 0 || !ok {
+		maxSize = 1 << 20
+	}
+	refresh := time.Minute
+	if r, ok := config["Refresh"].(string); ok && r != "" {
+		d, err := time.ParseDuration(r)
+		if err != nil {
+			panic("invalid jenkins refresh interval: " + r)
+		}
+		refresh = d
+	}
+	rewrite, _ := config["Rewrite"].(string)
+
+	p := Provider{
+		provider: "jenkins",
+		name:     name,
+		title:    title,
+		priority: priority,
+		url:      strings.TrimSuffix(u, "/"),
+		username: username,
+		token:    token,
+		paths:    paths,
+		builds:   int(builds),
+		maxSize:  maxSize,
+		refresh:  refresh,
+		rewrite:  rewrite,
+	}
+	if p.url != "" {
+		p.enabled = true
+	}
+
+	if err := f(&p); err != nil {
+		panic(err)
+	}
+}
+
+// Provider represents the provider
+type Provider struct {
+	provider string
+	enabled  bool
+	name     string
+	title    string
+	priority int64
+	url      string
+	username string
+	token    string
+	paths    []string
+	builds   int
+	maxSize  int64
+	refresh  time.Duration
+	rewrite  string
+
+	mu       sync.Mutex
+	jobs     []*SRJobs
+	fetched  time.Time
+	fetching bool
+}
+
+// Search makes a search
+func (provider *Provider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+
+	results := []map[string]interface{}{}
+	page, ok := args["page"].(int)
+	if page < 1 || !ok {
+		page = 1
+	}
+	limit, ok := args["limit"].(int)
+	if limit < 1 || !ok {
+		limit = 10
+	}
+	keyword, ok := args["keyword"].(string)
+
+	jobs, cached, err := provider.jobList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cached {
+		args["cache"] = "hit"
+	} else {
+		args["cache"] = "miss"
+	}
+
+	words := strings.Fields(strings.ToLower(keyword))
+	for _, v := range jobs {
+		if !match(strings.ToLower(v.FullName+" "+v.Description), words) {
+			continue
+		}
+
+		d := status(v)
+		if s := strings.Join(strings.Fields(v.Description), " "); s != "" {
+			d += " - " + s
+		}
+		if len(d) > 255 {
+			d = d[0:252] + "..."
+		}
+		ri := map[string]interface{}{
+			"Link":        v.URL,
+			"Title":       v.FullName,
+			"Description": d,
+		}
+		if v.LastBuild != nil {
+			ri["Date"] = time.Unix(0, v.LastBuild.Timestamp*int64(time.Millisecond))
+		}
+		results = append(results, ri)
+	}
+
+	// Build logs are searched only if there aren't enough job results
+	if provider.builds > 0 && len(words) > 0 && len(results) < page*limit {
+		lr, err := provider.searchLogs(ctx, jobs, strings.ToLower(keyword))
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, lr...)
+	}
+
+	if len(results) > 0 {
+		var l, h = 0, limit
+		if page > 1 {
+			h = (page * limit)
+			l = h - limit
+		}
+		if l > len(results) {
+			l = len(results)
+		}
+		if h > len(results) {
+			h = len(results)
+		}
+		results = results[l:h]
+	}
+
+	return results, nil
+}
+
+// searchLogs searches the console logs of the recent builds of the recently built jobs
+func (provider *Provider) searchLogs(ctx context.Context, jobs []*SRJobs, keyword string) ([]map[string]interface{}, error) {
+	var built []*SRJobs
+	for _, v := range jobs {
+		if v.LastBuild != nil {
+			built = append(built, v)
+		}
+	}
+	sort.Sort(byLastBuild(built))
+	if len(built) > maxLogJobs {
+		built = built[:maxLogJobs]
+	}
+
+	results := []map[string]interface{}{}
+	for _, j := range built {
+		var jb SRJobs
+		if err := provider.get(ctx, fmt.Sprintf("%s/api/json?tree=builds[number,result,building,timestamp,url]{0,%d}", strings.TrimSuffix(j.URL, "/"), provider.builds), &jb); err != nil {
+			return nil, err
+		}
+		for _, b := range jb.Builds {
+			line, err := provider.searchLog(ctx, b.URL, keyword)
+			if err != nil {
+				if err == context.DeadlineExceeded || err == context.Canceled {
+					return nil, err
+				}
+				continue
+			}
+			if line == "" {
+				continue
+			}
+			if len(line) > 255 {
+				line = line[0:252] + "..."
+			}
+			results = append(results, map[string]interface{}{
+				"Link":        strings.TrimSuffix(b.URL, "/") + "/console",
+				"Title":       fmt.Sprintf("%s #%d console", j.FullName, b.Number),
+				"Description": line,
+				"Date":        time.Unix(0, b.Timestamp*int64(time.Millisecond)),
+			})
+		}
+	}
+	return results, nil
+}
+
+// searchLog returns the first line of the given build log which contains the keyword
+func (provider *Provider) searchLog(ctx context.Context, buildURL, keyword string) (string, error) {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(buildURL, "/")+"/consoleText", nil)
+	if err != nil {
+		return "", errors.New("failed to prepare request. Error: " + err.Error())
+	}
+	if provider.username != "" || provider.token != "" {
+		req.SetBasicAuth(provider.username, provider.token)
+	}
+
+	res, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+	}
+	s := bufio.NewScanner(io.LimitReader(res.Body, provider.maxSize))
+	s.Buffer(make([]byte, 64*1024), 1<<20)
+	for s.Scan() {
+		if strings.Contains(strings.ToLower(s.Text()), keyword) {
+			return strings.Join(strings.Fields(s.Text()), " "), nil
+		}
+	}
+	return "", s.Err()
+}
+
+// jobList returns the jobs of the configured folders by using a cache.
+// It also returns whether the jobs are cached or not. The job list is fetched
+// without holding the lock and the other searches use the current list meanwhile.
+func (provider *Provider) jobList(ctx context.Context) ([]*SRJobs, bool, error) {
+
+	// Claim the refresh
+	provider.mu.Lock()
+	if provider.jobs != nil && (provider.fetching || time.Since(provider.fetched) < provider.refresh) {
+		jobs := provider.jobs
+		provider.mu.Unlock()
+		return jobs, true, nil
+	}
+	provider.fetching = true
+	provider.mu.Unlock()
+
+	jobs, err := provider.fetchJobs(ctx)
+
+	// Swap the jobs in
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	provider.fetching = false
+	if err != nil {
+		return nil, false, err
+	}
+	provider.jobs = jobs
+	provider.fetched = time.Now()
+	return jobs, false, nil
+}
+
+// fetchJobs fetches the jobs of the configured folders
+func (provider *Provider) fetchJobs(ctx context.Context) ([]*SRJobs, error) {
+
+	// The folders are fetched by nesting the tree parameter
+	tree := "name,url,description,color,lastBuild[number,result,building,timestamp]"
+	for i := 0; i < maxDepth; i++ {
+		tree = "name,url,description,color,lastBuild[number,result,building,timestamp],jobs[" + tree + "]"
+	}
+
+	paths := provider.paths
+	if len(paths) == 0 {
+		paths = []string{""}
+	}
+	jobs := []*SRJobs{}
+	for _, p := range paths {
+		u := provider.url
+		var prefix string
+		for _, v := range strings.Split(strings.Trim(p, "/"), "/") {
+			if v != "" {
+				u += "/job/" + url.PathEscape(v)
+				prefix += v + "/"
+			}
+		}
+		var sr SRJobs
+		if err := provider.get(ctx, u+"/api/json?tree=jobs["+tree+"]", &sr); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, flatten(sr.Jobs, prefix)...)
+	}
+	return jobs, nil
+}
+
+// Probe checks the reachability of the provider by the root API endpoint
+func (provider *Provider) Probe(ctx context.Context) error {
+	var v map[string]interface{}
+	return provider.get(ctx, provider.url+"/api/json?tree=mode", &v)
+}
+
+// get makes a GET request to the given URL and unmarshals the response into v
+func (provider *Provider) get(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return errors.New("failed to prepare request. Error: " + err.Error())
+	}
+	if provider.username != "" || provider.token != "" {
+		req.SetBasicAuth(provider.username, provider.token)
+	}
+
+	res, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return err
+	} else if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+	}
+	return nil
+}
+
+// flatten returns the jobs of the given folders with their full names
+func flatten(jobs []*SRJobs, prefix string) []*SRJobs {
+	var fl []*SRJobs
+	for _, v := range jobs {
+		v.FullName = prefix + v.Name
+		if v.Jobs != nil {
+			// Folders and multibranch projects
+			fl = append(fl, flatten(v.Jobs, v.FullName+"/")...)
+			continue
+		}
+		fl = append(fl, v)
+	}
+	return fl
+}
+
+// status returns the last build status of the given job
+func status(j *SRJobs) string {
+	switch {
+	case j.Color == "disabled" || j.Color == "notbuilt_disabled":
+		return "Disabled"
+	case j.LastBuild == nil:
+		return "Not built"
+	case j.LastBuild.Building:
+		return fmt.Sprintf("#%d building", j.LastBuild.Number)
+	}
+	return fmt.Sprintf("#%d %s", j.LastBuild.Number, j.LastBuild.Result)
+}
+
+// match checks whether the given text contains all the words or not
+func match(s string, words []string) bool {
+	for _, w := range words {
+		if !strings.Contains(s, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// byLastBuild implements sort.Interface for sorting the jobs by last build, newest first
+type byLastBuild []*SRJobs
+
+func (j byLastBuild) Len() int           { return len(j) }
+func (j byLastBuild) Swap(i, k int)      { j[i], j[k] = j[k], j[i] }
+func (j byLastBuild) Less(i, k int) bool { return j[i].LastBuild.Timestamp > j[k].LastBuild.Timestamp }
+
+// SRJobs represents the structure of the jobs
+type SRJobs struct {
+	Name        string       `json:"name"`
+	FullName    string       `json:"-"`
+	URL         string       `json:"url"`
+	Description string       `json:"description"`
+	Color       string       `json:"color"`
+	LastBuild   *SRJBuilds   `json:"lastBuild"`
+	Builds      []*SRJBuilds `json:"builds"`
+	Jobs        []*SRJobs    `json:"jobs"`
+}
+
+// SRJBuilds represents the structure of the job builds
+type SRJBuilds struct {
+	Number    int    `json:"number"`
+	Result    string `json:"result"`
+	Building  bool   `json:"building"`
+	Timestamp int64  `json:"timestamp"`
+	URL       string `json:"url"`
+}
@@ -0,0 +1,210 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package jira implements Jira provider
+package jira
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// Register registers the provider
+func Register(config map[string]interface{}, f func(interface{}) error) {
+
+	name, ok := config["Name"].(string)
+	if name == "" || !ok {
+		name = "jira"
+	}
+	title, ok := config["Title"].(string)
+	if title == "" || !ok {
+		title = "Jira"
+	}
+	priority, ok := config["Priority"].(int64)
+	if priority == 0 || !ok {
+		priority = 700
+	}
+	url, _ := config["URL"].(string)
+	username, _ := config["Username"].(string)
+	password, _ := config["Password"].(string)
+	token, _ := config["Token"].(string)
+	filter, _ := config["Filter"].(string)
+	query, _ := config["Query"].(string)
+	rewrite, _ := config["Rewrite"].(string)
+
+	p := Provider{
+		provider: "jira",
+		name:     name,
+		title:    title,
+		priority: priority,
+		url:      strings.TrimSuffix(url, "/"),
+		username: username,
+		password: password,
+		token:    token,
+		filter:   filter,
+		query:    query,
+		rewrite:  rewrite,
+	}
+	if p.url != "" {
+		p.enabled = true
+	}
+
+	if err := f(&p); err != nil {
+		panic(err)
+	}
+}
+
+// Provider represents the provider
+type Provider struct {
+	provider string
+	enabled  bool
+	name     string
+	title    string
+	priority int64
+	url      string
+	username string
+	password string
+	token    string
+	filter   string
+	query    string
+	rewrite  string
+}
+
+// Search makes a search
+func (provider *Provider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+
+	results := []map[string]interface{}{}
+	page, ok := args["page"].(int)
+	if page < 1 || !ok {
+		page = 1
+	}
+	limit, ok := args["limit"].(int)
+	if limit < 1 || !ok {
+		limit = 10
+	}
+	keyword, ok := args["keyword"].(string)
+
+	u := fmt.Sprintf("%s/rest/api/2/search?startAt=%d&maxResults=%d&fields=summary,status,assignee,updated&jql=%s", provider.url, (page-1)*limit, limit, url.QueryEscape(provider.jql(keyword)))
+	if provider.query != "" {
+		u += fmt.Sprintf("%s", provider.query)
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, errors.New("failed to prepare request. Error: " + err.Error())
+	}
+	if provider.username != "" && provider.token != "" {
+		req.SetBasicAuth(provider.username, provider.token)
+	} else if provider.username != "" || provider.password != "" {
+		req.SetBasicAuth(provider.username, provider.password)
+	} else if provider.token != "" {
+		req.Header.Set("Authorization", "Bearer "+provider.token)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return nil, err
+	} else if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return nil, errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	var sr SearchResult
+	if err := json.Unmarshal(data, &sr); err != nil {
+		return nil, errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+	}
+	for _, v := range sr.Issues {
+		if v.Fields == nil {
+			continue
+		}
+
+		d := "Unassigned"
+		if v.Fields.Assignee != nil && v.Fields.Assignee.DisplayName != "" {
+			d = "Assigned to " + v.Fields.Assignee.DisplayName
+		}
+		if v.Fields.Status != nil && v.Fields.Status.Name != "" {
+			d = v.Fields.Status.Name + " - " + d
+		}
+
+		var t time.Time
+		if ts, err := time.Parse("2006-01-02T15:04:05.000-0700", v.Fields.Updated); err == nil {
+			t = ts
+		}
+
+		ri := map[string]interface{}{
+			"Link":        fmt.Sprintf("%s/browse/%s", provider.url, v.Key),
+			"Title":       fmt.Sprintf("%s: %s", v.Key, v.Fields.Summary),
+			"Description": d,
+			"Date":        t,
+		}
+		results = append(results, ri)
+	}
+
+	return results, err
+}
+
+// jql returns the JQL query for the given keyword.
+// Keywords prefixed with `jql:` are passed through as is, otherwise a text
+// search is made within the configured filter.
+func (provider *Provider) jql(keyword string) string {
+	if strings.HasPrefix(keyword, "jql:") {
+		return strings.TrimSpace(strings.TrimPrefix(keyword, "jql:"))
+	}
+
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	q := fmt.Sprintf(`text ~ "%s"`, r.Replace(keyword))
+	if provider.filter != "" {
+		q = fmt.Sprintf("(%s) AND %s", provider.filter, q)
+	}
+	return q + " ORDER BY updated DESC"
+}
+
+// SearchResult represents the structure of the search result
+type SearchResult struct {
+	StartAt    int         `json:"startAt"`
+	MaxResults int         `json:"maxResults"`
+	Total      int         `json:"total"`
+	Issues     []*SRIssues `json:"issues"`
+}
+
+// SRIssues represents the structure of the search result issues
+type SRIssues struct {
+	ID     string     `json:"id"`
+	Key    string     `json:"key"`
+	Fields *SRIFields `json:"fields"`
+}
+
+// SRIFields represents the structure of the search result issues fields
+type SRIFields struct {
+	Summary  string      `json:"summary"`
+	Updated  string      `json:"updated"`
+	Status   *SRIFStatus `json:"status"`
+	Assignee *SRIFUser   `json:"assignee"`
+}
+
+// SRIFStatus represents the structure of the search result issues fields status
+type SRIFStatus struct {
+	Name string `json:"name"`
+}
+
+// SRIFUser represents the structure of the search result issues fields user
+type SRIFUser struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
@@ -0,0 +1,81 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package jira
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestSearch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/2/search" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if u, p, _ := r.BasicAuth(); u != "user" || p != "secret" {
+			t.Errorf("unexpected credentials %s:%s", u, p)
+		}
+		q := r.URL.Query()
+		if q.Get("startAt") != "20" || q.Get("maxResults") != "10" {
+			t.Errorf("unexpected paging startAt=%s maxResults=%s", q.Get("startAt"), q.Get("maxResults"))
+		}
+		if e := `(project = OPS) AND text ~ "a \"b\"" ORDER BY updated DESC`; q.Get("jql") != e {
+			t.Errorf("expected jql %s, got %s", e, q.Get("jql"))
+		}
+		fmt.Fprint(w, `{"startAt":20,"maxResults":10,"total":21,"issues":[
+			{"key":"OPS-1","fields":{"summary":"Fix it","updated":"2017-02-01T10:00:00.000+0000","status":{"name":"Open"},"assignee":{"displayName":"Jane"}}},
+			{"key":"OPS-2"}
+		]}`)
+	}))
+	defer ts.Close()
+
+	p := Provider{url: ts.URL, username: "user", token: "secret", filter: "project = OPS"}
+	res, err := p.Search(context.Background(), map[string]interface{}{"page": 3, "limit": 10, "keyword": `a "b"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(res))
+	}
+	if l := res[0]["Link"]; l != ts.URL+"/browse/OPS-1" {
+		t.Errorf("unexpected link %v", l)
+	}
+	if tt := res[0]["Title"]; tt != "OPS-1: Fix it" {
+		t.Errorf("unexpected title %v", tt)
+	}
+	if d := res[0]["Description"]; d != "Open - Assigned to Jane" {
+		t.Errorf("unexpected description %v", d)
+	}
+}
+
+func TestSearchError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"errorMessages":["bad jql"]}`, http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	p := Provider{url: ts.URL}
+	_, err := p.Search(context.Background(), map[string]interface{}{"page": 1, "limit": 10, "keyword": "jql:bad"})
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("expected a bad response error, got %v", err)
+	}
+}
+
+func TestJQL(t *testing.T) {
+	p := Provider{}
+	if q := p.jql("jql: project = OPS"); q != "project = OPS" {
+		t.Errorf("unexpected raw jql %s", q)
+	}
+	if q := p.jql(`c:\d`); q != `text ~ "c:\\d" ORDER BY updated DESC` {
+		t.Errorf("unexpected jql %s", q)
+	}
+}
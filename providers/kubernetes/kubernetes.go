@@ -0,0 +1,478 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package kubernetes implements Kubernetes provider
+package kubernetes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// serviceAccountPath is the path of the in-cluster service account files
+	serviceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	// listLimit is the chunk size of the resource lists
+	listLimit = 500
+)
+
+// kinds represents the supported resource kinds
+var kinds = map[string]struct {
+	path     string
+	singular string
+}{
+	"configmaps":  {"/api/v1", "configmap"},
+	"deployments": {"/apis/apps/v1", "deployment"},
+	"ingresses":   {"/apis/networking.k8s.io/v1", "ingress"},
+	"pods":        {"/api/v1", "pod"},
+	"services":    {"/api/v1", "service"},
+}
+
+// Register registers the provider
+func Register(config map[string]interface{}, f func(interface{}) error) {
+
+	name, ok := config["Name"].(string)
+	if name == "" || !ok {
+		name = "kubernetes"
+	}
+	title, ok := config["Title"].(string)
+	if title == "" || !ok {
+		title = "Kubernetes"
+	}
+	priority, ok := config["Priority"].(int64)
+	if priority == 0 || !ok {
+		priority = 600
+	}
+	url, _ := config["URL"].(string)
+	token, _ := config["Token"].(string)
+	kubeconfig, _ := config["Kubeconfig"].(string)
+	kubecontext, _ := config["Context"].(string)
+	models, _ := config["Models"].([]string)
+	if len(models) == 0 {
+		models = []string{"deployments", "services", "configmaps", "ingresses"}
+	}
+	for _, v := range models {
+		if _, ok := kinds[v]; !ok {
+			panic("invalid kubernetes resource kind: " + v)
+		}
+	}
+	namespaces, _ := config["Namespaces"].([]string)
+	dashboard, _ := config["Dashboard"].(string)
+	query, _ := config["Query"].(string)
+	rewrite, _ := config["Rewrite"].(string)
+
+	p := Provider{
+		provider:   "kubernetes",
+		name:       name,
+		title:      title,
+		priority:   priority,
+		noui:       true,
+		kinds:      models,
+		namespaces: namespaces,
+		dashboard:  dashboard,
+		query:      query,
+		rewrite:    rewrite,
+	}
+
+	// Connection
+	var err error
+	if url != "" {
+		p.url, p.token, p.client = url, token, http.DefaultClient
+	} else if kubeconfig != "" {
+		err = p.loadKubeconfig(kubeconfig, kubecontext)
+	} else if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		err = p.loadInCluster()
+	}
+	if err != nil {
+		panic("failed to configure kubernetes provider due to " + err.Error())
+	}
+	p.url = strings.TrimSuffix(p.url, "/")
+	if p.url != "" {
+		p.enabled = true
+	}
+
+	if err := f(&p); err != nil {
+		panic(err)
+	}
+}
+
+// Provider represents the provider
+type Provider struct {
+	provider   string
+	enabled    bool
+	name       string
+	title      string
+	priority   int64
+	noui       bool
+	url        string
+	token      string
+	username   string
+	password   string
+	client     *http.Client
+	kinds      []string
+	namespaces []string
+	dashboard  string
+	query      string
+	rewrite    string
+}
+
+// Search makes a search
+func (provider *Provider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+
+	results := []map[string]interface{}{}
+	page, ok := args["page"].(int)
+	if page < 1 || !ok {
+		page = 1
+	}
+	limit, ok := args["limit"].(int)
+	if limit < 1 || !ok {
+		limit = 10
+	}
+	keyword, ok := args["keyword"].(string)
+	keyword = strings.ToLower(keyword)
+
+	// The resources are listed by chunks until there are enough matches for the page
+	namespaces := provider.namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	need := page * limit
+	for _, kind := range provider.kinds {
+		for _, ns := range namespaces {
+			if len(results) >= need {
+				break
+			}
+			rl, err := provider.list(ctx, kind, ns, keyword, need-len(results))
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, rl...)
+		}
+	}
+
+	if len(results) > 0 {
+		var l, h = 0, limit
+		if page > 1 {
+			h = (page * limit)
+			l = h - limit
+		}
+		if l > len(results) {
+			l = len(results)
+		}
+		if h > len(results) {
+			h = len(results)
+		}
+		results = results[l:h]
+	}
+
+	return results, nil
+}
+
+// list returns the matched resources of the given kind and namespace.
+// The resources are listed by chunks of listLimit and only their metadata
+// is requested. The listing stops when there are enough matches.
+func (provider *Provider) list(ctx context.Context, kind, ns, keyword string, need int) ([]map[string]interface{}, error) {
+	results := []map[string]interface{}{}
+	k := kinds[kind]
+	cont := ""
+	for {
+		u := fmt.Sprintf("%s%s/%s", provider.url, k.path, kind)
+		if ns != "" {
+			u = fmt.Sprintf("%s%s/namespaces/%s/%s", provider.url, k.path, url.QueryEscape(ns), kind)
+		}
+		u += fmt.Sprintf("?limit=%d", listLimit)
+		if cont != "" {
+			u += fmt.Sprintf("&continue=%s", url.QueryEscape(cont))
+		}
+		if provider.query != "" {
+			u += fmt.Sprintf("%s", provider.query)
+		}
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, errors.New("failed to prepare request. Error: " + err.Error())
+		}
+		if provider.token != "" {
+			req.Header.Set("Authorization", "Bearer "+provider.token)
+		} else if provider.username != "" || provider.password != "" {
+			req.SetBasicAuth(provider.username, provider.password)
+		}
+		// Only the metadata is requested. The older API servers fall back to the full objects.
+		req.Header.Set("Accept", "application/json;as=PartialObjectMetadataList;g=meta.k8s.io;v=v1,application/json")
+
+		res, err := ctxhttp.Do(ctx, provider.client, req)
+		if err != nil {
+			return nil, err
+		} else if res.StatusCode < 200 || res.StatusCode > 299 {
+			res.Body.Close()
+			return nil, errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+		}
+		data, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		var sr SearchResult
+		if err := json.Unmarshal(data, &sr); err != nil {
+			return nil, errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+		}
+		for _, v := range sr.Items {
+			if v.Metadata == nil {
+				continue
+			}
+			m := v.Metadata
+			labels := pairs(m.Labels)
+			if !match(keyword, m.Name, labels, pairs(m.Annotations)) {
+				continue
+			}
+
+			var t time.Time
+			if ts, err := time.Parse(time.RFC3339, m.CreationTimestamp); err == nil {
+				t = ts
+			}
+
+			d := strings.Join(labels, ", ")
+			if len(d) > 255 {
+				d = d[0:252] + "..."
+			}
+
+			ri := map[string]interface{}{
+				"Link":        provider.link(kind, m.Namespace, m.Name),
+				"Title":       fmt.Sprintf("%s/%s/%s", k.singular, m.Namespace, m.Name),
+				"Description": d,
+				"Date":        t,
+			}
+			results = append(results, ri)
+		}
+
+		if len(results) >= need || sr.Metadata == nil || sr.Metadata.Continue == "" {
+			return results, nil
+		}
+		cont = sr.Metadata.Continue
+	}
+}
+
+// link returns the link of the given resource.
+// The dashboard template supports `{kind}`, `{namespace}` and `{name}` placeholders.
+func (provider *Provider) link(kind, namespace, name string) string {
+	if provider.dashboard == "" {
+		if namespace == "" {
+			return fmt.Sprintf("%s%s/%s/%s", provider.url, kinds[kind].path, kind, name)
+		}
+		return fmt.Sprintf("%s%s/namespaces/%s/%s/%s", provider.url, kinds[kind].path, namespace, kind, name)
+	}
+	return strings.NewReplacer("{kind}", kinds[kind].singular, "{namespace}", namespace, "{name}", name).Replace(provider.dashboard)
+}
+
+// loadKubeconfig configures the connection by the given kubeconfig file
+func (provider *Provider) loadKubeconfig(file, kubecontext string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var kc Kubeconfig
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		return errors.New("failed to unmarshal kubeconfig due to " + err.Error())
+	}
+	dir := filepath.Dir(file)
+
+	if kubecontext == "" {
+		kubecontext = kc.CurrentContext
+	}
+	var cn, un string
+	for _, v := range kc.Contexts {
+		if v.Name == kubecontext {
+			cn, un = v.Context.Cluster, v.Context.User
+		}
+	}
+	if cn == "" {
+		return errors.New("context " + kubecontext + " couldn't be found")
+	}
+
+	tc := &tls.Config{}
+	for _, v := range kc.Clusters {
+		if v.Name != cn {
+			continue
+		}
+		provider.url = v.Cluster.Server
+		tc.InsecureSkipVerify = v.Cluster.InsecureSkipTLSVerify
+		ca, err := fileOrData(dir, v.Cluster.CertificateAuthority, v.Cluster.CertificateAuthorityData)
+		if err != nil {
+			return err
+		}
+		if ca != nil {
+			tc.RootCAs = x509.NewCertPool()
+			tc.RootCAs.AppendCertsFromPEM(ca)
+		}
+	}
+	for _, v := range kc.Users {
+		if v.Name != un {
+			continue
+		}
+		provider.token = v.User.Token
+		if provider.token == "" && v.User.TokenFile != "" {
+			t, err := fileOrData(dir, v.User.TokenFile, "")
+			if err != nil {
+				return err
+			}
+			provider.token = strings.TrimSpace(string(t))
+		}
+		provider.username, provider.password = v.User.Username, v.User.Password
+		cert, err := fileOrData(dir, v.User.ClientCertificate, v.User.ClientCertificateData)
+		if err != nil {
+			return err
+		}
+		key, err := fileOrData(dir, v.User.ClientKey, v.User.ClientKeyData)
+		if err != nil {
+			return err
+		}
+		if cert != nil && key != nil {
+			c, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return err
+			}
+			tc.Certificates = []tls.Certificate{c}
+		}
+	}
+	provider.client = &http.Client{Transport: &http.Transport{TLSClientConfig: tc, Proxy: http.ProxyFromEnvironment}}
+
+	return nil
+}
+
+// loadInCluster configures the connection by the service account of the pod
+func (provider *Provider) loadInCluster() error {
+	token, err := ioutil.ReadFile(filepath.Join(serviceAccountPath, "token"))
+	if err != nil {
+		return err
+	}
+	ca, err := ioutil.ReadFile(filepath.Join(serviceAccountPath, "ca.crt"))
+	if err != nil {
+		return err
+	}
+	tc := &tls.Config{RootCAs: x509.NewCertPool()}
+	tc.RootCAs.AppendCertsFromPEM(ca)
+
+	provider.url = "https://" + net.JoinHostPort(os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT"))
+	provider.token = strings.TrimSpace(string(token))
+	provider.client = &http.Client{Transport: &http.Transport{TLSClientConfig: tc}}
+
+	return nil
+}
+
+// fileOrData returns the content of the given file or base64 encoded data
+func fileOrData(dir, file, data string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if file == "" {
+		return nil, nil
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	return ioutil.ReadFile(file)
+}
+
+// pairs returns the sorted key=value pairs of the given map
+func pairs(m map[string]string) []string {
+	l := []string{}
+	for k, v := range m {
+		l = append(l, k+"="+v)
+	}
+	sort.Strings(l)
+	return l
+}
+
+// match checks whether the given name or key=value pairs contain the keyword or not
+func match(keyword, name string, pl ...[]string) bool {
+	if strings.Contains(strings.ToLower(name), keyword) {
+		return true
+	}
+	for _, p := range pl {
+		for _, v := range p {
+			if strings.Contains(strings.ToLower(v), keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SearchResult represents the structure of the search result
+type SearchResult struct {
+	Metadata *SRMetadata `json:"metadata"`
+	Items    []*SRItems  `json:"items"`
+}
+
+// SRMetadata represents the structure of the search result metadata field
+type SRMetadata struct {
+	Continue string `json:"continue"`
+}
+
+// SRItems represents the structure of the search result items
+type SRItems struct {
+	Metadata *SRIMetadata `json:"metadata"`
+}
+
+// SRIMetadata represents the structure of the search result items metadata field
+type SRIMetadata struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace"`
+	Labels            map[string]string `json:"labels"`
+	Annotations       map[string]string `json:"annotations"`
+	CreationTimestamp string            `json:"creationTimestamp"`
+}
+
+// Kubeconfig represents the structure of a kubeconfig file
+type Kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			Username              string `yaml:"username"`
+			Password              string `yaml:"password"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
@@ -0,0 +1,106 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package kubernetes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+// fakeAPIServer returns a fake Kubernetes API server which lists the deployments
+// of the default namespace by chunks of two and records the requests
+func fakeAPIServer(t *testing.T, requests *[]string) *httptest.Server {
+	chunks := map[string]string{
+		"": `{"metadata":{"continue":"c1"},"items":[
+			{"metadata":{"name":"web","namespace":"default","labels":{"app":"web"},"creationTimestamp":"2017-02-01T10:00:00Z"}},
+			{"metadata":{"name":"db","namespace":"default","labels":{"app":"db"}}}]}`,
+		"c1": `{"metadata":{"continue":"c2"},"items":[
+			{"metadata":{"name":"worker","namespace":"default","annotations":{"team":"web"}}},
+			{"metadata":{"name":"cache","namespace":"default"}}]}`,
+		"c2": `{"metadata":{},"items":[
+			{"metadata":{"name":"web-canary","namespace":"default"}}]}`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.RawQuery)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Accept"), "application/json;as=PartialObjectMetadataList") {
+			t.Errorf("unexpected accept header %s", r.Header.Get("Accept"))
+		}
+		if r.URL.Path != "/apis/apps/v1/namespaces/default/deployments" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("limit") != fmt.Sprintf("%d", listLimit) {
+			t.Errorf("unexpected limit %s", r.URL.Query().Get("limit"))
+		}
+		c, ok := chunks[r.URL.Query().Get("continue")]
+		if !ok {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		fmt.Fprint(w, c)
+	}))
+}
+
+func TestSearch(t *testing.T) {
+	var requests []string
+	ts := fakeAPIServer(t, &requests)
+	defer ts.Close()
+
+	p := Provider{url: ts.URL, token: "secret", kinds: []string{"deployments"}, namespaces: []string{"default"}, dashboard: "https://k8s/#!/{kind}/{namespace}/{name}"}
+
+	// The first page only needs the first two chunks
+	res, err := p.Search(context.Background(), map[string]interface{}{"page": 1, "limit": 2, "keyword": "WEB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0]["Title"] != "deployment/default/web" || res[1]["Title"] != "deployment/default/worker" {
+		t.Fatalf("unexpected results %v", res)
+	}
+	if l := res[0]["Link"]; l != "https://k8s/#!/deployment/default/web" {
+		t.Errorf("unexpected link %v", l)
+	}
+	if d := res[0]["Description"]; d != "app=web" {
+		t.Errorf("unexpected description %v", d)
+	}
+	if len(requests) != 2 {
+		t.Errorf("expected 2 requests, got %v", requests)
+	}
+
+	// The second page lists all the chunks
+	requests = nil
+	res, err = p.Search(context.Background(), map[string]interface{}{"page": 2, "limit": 2, "keyword": "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0]["Title"] != "deployment/default/web-canary" {
+		t.Fatalf("unexpected results %v", res)
+	}
+	if len(requests) != 3 {
+		t.Errorf("expected 3 requests, got %v", requests)
+	}
+}
+
+func TestSearchError(t *testing.T) {
+	var requests []string
+	ts := fakeAPIServer(t, &requests)
+	defer ts.Close()
+
+	p := Provider{url: ts.URL, token: "invalid", kinds: []string{"deployments"}, namespaces: []string{"default"}}
+	_, err := p.Search(context.Background(), map[string]interface{}{"page": 1, "limit": 10, "keyword": "web"})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected a bad response error, got %v", err)
+	}
+}
@@ -0,0 +1,310 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+)
+
+// BER tags which are used by the LDAP messages
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x30
+	tagSet         = 0x31
+
+	tagBindRequest       = 0x60
+	tagBindResponse      = 0x61
+	tagUnbindRequest     = 0x42
+	tagSearchRequest     = 0x63
+	tagSearchResultEntry = 0x64
+	tagSearchResultDone  = 0x65
+	tagSearchResultRef   = 0x73
+	tagAuthSimple        = 0x80
+)
+
+// maxPacketSize is the maximum size of a received packet
+const maxPacketSize = 16 << 20
+
+// packet represents a BER encoded element
+type packet struct {
+	tag      byte
+	value    []byte
+	children []*packet
+}
+
+// constructed checks whether the packet contains other packets or not
+func (p *packet) constructed() bool {
+	return p.tag&0x20 != 0
+}
+
+// bytes encodes the packet
+func (p *packet) bytes() []byte {
+	v := p.value
+	if p.constructed() {
+		v = nil
+		for _, c := range p.children {
+			v = append(v, c.bytes()...)
+		}
+	}
+	b := []byte{p.tag}
+	if l := len(v); l < 0x80 {
+		b = append(b, byte(l))
+	} else {
+		var lb []byte
+		for ; l > 0; l >>= 8 {
+			lb = append([]byte{byte(l)}, lb...)
+		}
+		b = append(b, 0x80|byte(len(lb)))
+		b = append(b, lb...)
+	}
+	return append(b, v...)
+}
+
+// str returns the value of the packet as a string
+func (p *packet) str() string {
+	return string(p.value)
+}
+
+// int returns the value of the packet as an integer
+func (p *packet) int() int {
+	n := 0
+	for i, b := range p.value {
+		if i == 0 && b&0x80 != 0 {
+			n = -1
+		}
+		n = n<<8 | int(b)
+	}
+	return n
+}
+
+// newConstructed returns a new constructed packet
+func newConstructed(tag byte, children ...*packet) *packet {
+	return &packet{tag: tag | 0x20, children: children}
+}
+
+// newString returns a new octet string packet
+func newString(tag byte, s string) *packet {
+	return &packet{tag: tag, value: []byte(s)}
+}
+
+// newInt returns a new integer packet
+func newInt(tag byte, n int) *packet {
+	var b []byte
+	for {
+		b = append([]byte{byte(n)}, b...)
+		n >>= 8
+		if (n == 0 && b[0]&0x80 == 0) || (n == -1 && b[0]&0x80 != 0) {
+			break
+		}
+	}
+	return &packet{tag: tag, value: b}
+}
+
+// newBool returns a new boolean packet
+func newBool(v bool) *packet {
+	if v {
+		return &packet{tag: tagBoolean, value: []byte{0xff}}
+	}
+	return &packet{tag: tagBoolean, value: []byte{0x00}}
+}
+
+// readPacket reads and decodes a packet from the given reader
+func readPacket(r *bufio.Reader) (*packet, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	lb, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	l := int(lb)
+	if lb&0x80 != 0 {
+		n := int(lb & 0x7f)
+		if n == 0 || n > 4 {
+			return nil, errors.New("unsupported BER length")
+		}
+		l = 0
+		for i := 0; i < n; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			l = l<<8 | int(b)
+		}
+		if l > maxPacketSize {
+			return nil, errors.New("BER packet is too large")
+		}
+	}
+	v := make([]byte, l)
+	if _, err := io.ReadFull(r, v); err != nil {
+		return nil, err
+	}
+	return decodePacket(tag, v)
+}
+
+// decodePacket decodes the value of the given tag
+func decodePacket(tag byte, v []byte) (*packet, error) {
+	p := &packet{tag: tag, value: v}
+	if !p.constructed() {
+		return p, nil
+	}
+	r := bufio.NewReader(bytes.NewReader(v))
+	for {
+		c, err := readPacket(r)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		p.children = append(p.children, c)
+	}
+	return p, nil
+}
+
+// parseFilter parses the given LDAP filter string (RFC 4515)
+func parseFilter(s string) (*packet, error) {
+	f, rest, err := parseFilterPart(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, errors.New("invalid LDAP filter: unexpected " + rest)
+	}
+	return f, nil
+}
+
+// parseFilterPart parses a parenthesized filter and returns the rest of the string
+func parseFilterPart(s string) (*packet, string, error) {
+	if !strings.HasPrefix(s, "(") {
+		return nil, "", errors.New("invalid LDAP filter: missing (")
+	}
+	s = s[1:]
+	if s == "" {
+		return nil, "", errors.New("invalid LDAP filter: unexpected end")
+	}
+
+	switch s[0] {
+	case '&', '|':
+		tag := byte(0xa0)
+		if s[0] == '|' {
+			tag = 0xa1
+		}
+		f := &packet{tag: tag}
+		s = s[1:]
+		for strings.HasPrefix(s, "(") {
+			c, rest, err := parseFilterPart(s)
+			if err != nil {
+				return nil, "", err
+			}
+			f.children = append(f.children, c)
+			s = rest
+		}
+		if !strings.HasPrefix(s, ")") {
+			return nil, "", errors.New("invalid LDAP filter: missing )")
+		}
+		return f, s[1:], nil
+	case '!':
+		c, rest, err := parseFilterPart(s[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		if !strings.HasPrefix(rest, ")") {
+			return nil, "", errors.New("invalid LDAP filter: missing )")
+		}
+		return &packet{tag: 0xa2, children: []*packet{c}}, rest[1:], nil
+	}
+
+	// Item
+	i := strings.Index(s, ")")
+	if i < 0 {
+		return nil, "", errors.New("invalid LDAP filter: missing )")
+	}
+	item, rest := s[:i], s[i+1:]
+	// The item is split at the first "=" since the attribute can't contain it
+	// but the value can. The operator is the "=" and its preceding ~, > or <.
+	var attr, op, val string
+	if j := strings.Index(item, "="); j > 0 {
+		attr, op, val = item[:j], "=", item[j+1:]
+		if k := len(attr) - 1; strings.IndexByte("~><", attr[k]) >= 0 {
+			attr, op = attr[:k], attr[k:]+op
+		}
+	}
+	if attr == "" {
+		return nil, "", errors.New("invalid LDAP filter item: " + item)
+	}
+
+	switch {
+	case op == "=" && val == "*":
+		return &packet{tag: 0x87, value: []byte(attr)}, rest, nil
+	case op == "=" && strings.Contains(val, "*"):
+		subs := &packet{tag: tagSequence}
+		parts := strings.Split(val, "*")
+		for j, v := range parts {
+			if v == "" {
+				continue
+			}
+			uv, err := unescapeFilterValue(v)
+			if err != nil {
+				return nil, "", err
+			}
+			t := byte(0x81)
+			if j == 0 {
+				t = 0x80
+			} else if j == len(parts)-1 {
+				t = 0x82
+			}
+			subs.children = append(subs.children, newString(t, uv))
+		}
+		return &packet{tag: 0xa4, children: []*packet{newString(tagOctetString, attr), subs}}, rest, nil
+	}
+
+	tag := map[string]byte{"=": 0xa3, ">=": 0xa5, "<=": 0xa6, "~=": 0xa8}[op]
+	uv, err := unescapeFilterValue(val)
+	if err != nil {
+		return nil, "", err
+	}
+	return &packet{tag: tag, children: []*packet{newString(tagOctetString, attr), newString(tagOctetString, uv)}}, rest, nil
+}
+
+// escapeFilterValue escapes the given value for LDAP filters.
+// The operator characters are escaped as well so a value can't be mistaken for an operator.
+func escapeFilterValue(s string) string {
+	return strings.NewReplacer(`\`, `\5c`, `*`, `\2a`, `(`, `\28`, `)`, `\29`, "\x00", `\00`, `=`, `\3d`, `~`, `\7e`, `>`, `\3e`, `<`, `\3c`).Replace(s)
+}
+
+// unescapeFilterValue unescapes the given LDAP filter value
+func unescapeFilterValue(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		if i+3 > len(s) {
+			return "", errors.New("invalid LDAP filter escape: " + s)
+		}
+		h, err := hex.DecodeString(s[i+1 : i+3])
+		if err != nil {
+			return "", errors.New("invalid LDAP filter escape: " + s)
+		}
+		b = append(b, h...)
+		i += 2
+	}
+	return string(b), nil
+}
@@ -0,0 +1,167 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// decode decodes the given encoded packet
+func decode(t *testing.T, b []byte) *packet {
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestIntRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, 127, 128, 255, 256, 65535, 1 << 24, -1, -128, -129, -65536} {
+		p := decode(t, newInt(tagInteger, n).bytes())
+		if p.tag != tagInteger || p.int() != n {
+			t.Errorf("expected %d, got %d (tag %x)", n, p.int(), p.tag)
+		}
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	long := strings.Repeat("x", 70000)
+	m := newConstructed(tagSequence,
+		newInt(tagInteger, 7),
+		newConstructed(tagSearchResultEntry,
+			newString(tagOctetString, "cn=jane,dc=example,dc=com"),
+			newConstructed(tagSequence, newString(tagOctetString, long)),
+		),
+		newBool(true),
+	)
+	b := m.bytes()
+	// The long string needs a 3 byte length
+	if !bytes.Contains(b, []byte{tagOctetString, 0x83, 0x01, 0x11, 0x70}) {
+		t.Errorf("unexpected long length encoding")
+	}
+
+	p := decode(t, b)
+	if !bytes.Equal(p.bytes(), b) {
+		t.Fatal("expected the same encoding after a round trip")
+	}
+	if len(p.children) != 3 || p.children[0].int() != 7 || p.children[2].value[0] != 0xff {
+		t.Fatalf("unexpected packet %+v", p)
+	}
+	e := p.children[1]
+	if e.tag != tagSearchResultEntry || e.children[0].str() != "cn=jane,dc=example,dc=com" || e.children[1].children[0].str() != long {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
+
+func TestReadPacketErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"too large", []byte{tagOctetString, 0x84, 0xff, 0xff, 0xff, 0xff}},
+		{"unsupported length", []byte{tagOctetString, 0x85, 0x01, 0x00, 0x00, 0x00, 0x00}},
+		{"indefinite length", []byte{tagSequence, 0x80}},
+		{"truncated", []byte{tagOctetString, 0x05, 'a', 'b'}},
+		{"truncated child", []byte{tagSequence, 0x03, tagOctetString, 0x05, 'a'}},
+	}
+	for _, tt := range tests {
+		if _, err := readPacket(bufio.NewReader(bytes.NewReader(tt.data))); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	f, err := parseFilter(`(&(objectClass=person)(!(cn=*a\2ab*))(mail=*)(uid>=j))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.tag != 0xa0 || len(f.children) != 4 {
+		t.Fatalf("unexpected and filter %+v", f)
+	}
+	if c := f.children[0]; c.tag != 0xa3 || c.children[0].str() != "objectClass" || c.children[1].str() != "person" {
+		t.Errorf("unexpected equality filter %+v", c)
+	}
+	not := f.children[1]
+	if not.tag != 0xa2 || not.children[0].tag != 0xa4 {
+		t.Fatalf("unexpected not filter %+v", not)
+	}
+	subs := not.children[0].children[1].children
+	if len(subs) != 1 || subs[0].tag != 0x81 || subs[0].str() != "a*b" {
+		t.Errorf("unexpected substrings %+v", subs)
+	}
+	if c := f.children[2]; c.tag != 0x87 || c.str() != "mail" {
+		t.Errorf("unexpected present filter %+v", c)
+	}
+	if c := f.children[3]; c.tag != 0xa5 {
+		t.Errorf("unexpected greater or equal filter %+v", c)
+	}
+
+	// The filter can be encoded and decoded
+	if p := decode(t, f.bytes()); !bytes.Equal(p.bytes(), f.bytes()) {
+		t.Error("expected the same encoding after a round trip")
+	}
+
+	for _, v := range []string{"", "cn=a", "(cn=a", "(&(cn=a)", "(cn)", `(cn=\zz)`, "(cn=a))"} {
+		if _, err := parseFilter(v); err == nil {
+			t.Errorf("expected an error for %q", v)
+		}
+	}
+}
+
+func TestEscapeFilterValue(t *testing.T) {
+	v := `a*(b)\c<=>~` + "\x00"
+	e := escapeFilterValue(v)
+	if e != `a\2a\28b\29\5cc\3c\3d\3e\7e\00` {
+		t.Errorf("unexpected escaped value %s", e)
+	}
+	if u, err := unescapeFilterValue(e); err != nil || u != v {
+		t.Errorf("unexpected unescaped value %q (%v)", u, err)
+	}
+
+	// The operators of the keyword don't split the filter item
+	for _, k := range []string{"a>=b", "a<=b", "a~=b", "a=b"} {
+		f, err := parseFilter("(cn=*" + escapeFilterValue(k) + "*)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.tag != 0xa4 || f.children[0].str() != "cn" || f.children[1].children[0].str() != k {
+			t.Errorf("%s: unexpected substrings filter %+v", k, f)
+		}
+	}
+}
+
+func TestParseFilterOperators(t *testing.T) {
+	tests := []struct {
+		filter string
+		tag    byte
+		attr   string
+		value  string
+	}{
+		{"(cn=a>=b)", 0xa3, "cn", "a>=b"},
+		{"(cn>=a<=b)", 0xa5, "cn", "a<=b"},
+		{"(cn<=a)", 0xa6, "cn", "a"},
+		{"(cn~=a=b)", 0xa8, "cn", "a=b"},
+	}
+	for _, tt := range tests {
+		f, err := parseFilter(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.tag != tt.tag || f.children[0].str() != tt.attr || f.children[1].str() != tt.value {
+			t.Errorf("%s: unexpected filter %+v", tt.filter, f)
+		}
+	}
+	for _, v := range []string{"(cn)", "(=a)", "(>=a)"} {
+		if _, err := parseFilter(v); err == nil {
+			t.Errorf("%s: expected an error", v)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package ldap implements LDAP and Active Directory provider
+package ldap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// defaultFilter is the filter template which is used when there is no filter
+const defaultFilter = "(|(cn=*{keyword}*)(displayName=*{keyword}*)(mail=*{keyword}*)(uid={keyword})(sAMAccountName={keyword})(title=*{keyword}*))"
+
+// attributes is the list of the requested entry attributes
+var attributes = []string{"cn", "displayName", "mail", "uid", "sAMAccountName", "title", "department", "telephoneNumber", "description", "objectClass"}
+
+// Register registers the provider
+func Register(config map[string]interface{}, f func(interface{}) error) {
+
+	name, ok := config["Name"].(string)
+	if name == "" || !ok {
+		name = "ldap"
+	}
+	title, ok := config["Title"].(string)
+	if title == "" || !ok {
+		title = "People"
+	}
+	priority, ok := config["Priority"].(int64)
+	if priority == 0 || !ok {
+		priority = 600
+	}
+	u, _ := config["URL"].(string)
+	username, _ := config["Username"].(string)
+	password, _ := config["Password"].(string)
+	base, _ := config["Base"].(string)
+	filter, _ := config["Filter"].(string)
+	if filter == "" {
+		filter = defaultFilter
+	}
+	if _, err := parseFilter(strings.Replace(filter, "{keyword}", "x", -1)); err != nil {
+		panic(err)
+	}
+	profile, _ := config["Profile"].(string)
+	rewrite, _ := config["Rewrite"].(string)
+
+	p := Provider{
+		provider: "ldap",
+		name:     name,
+		title:    title,
+		priority: priority,
+		username: username,
+		password: password,
+		base:     base,
+		filter:   filter,
+		profile:  profile,
+		rewrite:  rewrite,
+	}
+	if u != "" {
+		pu, err := url.Parse(u)
+		if err != nil || (pu.Scheme != "ldap" && pu.Scheme != "ldaps") {
+			panic("invalid ldap url: " + u)
+		}
+		p.url = pu
+		p.enabled = true
+	}
+
+	if err := f(&p); err != nil {
+		panic(err)
+	}
+}
+
+// Provider represents the provider
+type Provider struct {
+	provider string
+	enabled  bool
+	name     string
+	title    string
+	priority int64
+	url      *url.URL
+	username string
+	password string
+	base     string
+	filter   string
+	profile  string
+	rewrite  string
+}
+
+// Search makes a search
+func (provider *Provider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+
+	results := []map[string]interface{}{}
+	page, ok := args["page"].(int)
+	if page < 1 || !ok {
+		page = 1
+	}
+	limit, ok := args["limit"].(int)
+	if limit < 1 || !ok {
+		limit = 10
+	}
+	keyword, ok := args["keyword"].(string)
+
+	if provider.url == nil {
+		return nil, errors.New("missing ldap url")
+	}
+	filter, err := parseFilter(strings.Replace(provider.filter, "{keyword}", escapeFilterValue(keyword), -1))
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := provider.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+
+	if provider.username != "" || provider.password != "" {
+		if err := c.bind(provider.username, provider.password); err != nil {
+			return nil, err
+		}
+	}
+	entries, err := c.search(provider.base, filter, page*limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range entries {
+		tt := v.first("displayName", "cn", "uid", "sAMAccountName")
+		if tt == "" {
+			tt = v.dn
+		}
+		if m := v.first("mail"); m != "" {
+			tt = fmt.Sprintf("%s <%s>", tt, m)
+		}
+
+		var dl []string
+		if v.group() {
+			dl = append(dl, "Group")
+		}
+		for _, a := range []string{"title", "department", "telephoneNumber", "description"} {
+			if s := v.first(a); s != "" {
+				dl = append(dl, s)
+			}
+		}
+		d := strings.Join(dl, " - ")
+		if len(d) > 255 {
+			d = d[0:252] + "..."
+		}
+
+		ri := map[string]interface{}{
+			"Link":        provider.link(v),
+			"Title":       tt,
+			"Description": d,
+		}
+		results = append(results, ri)
+	}
+
+	if len(results) > 0 {
+		var l, h = 0, limit
+		if page > 1 {
+			h = (page * limit)
+			l = h - limit
+		}
+		if l > len(results) {
+			l = len(results)
+		}
+		if h > len(results) {
+			h = len(results)
+		}
+		results = results[l:h]
+	}
+
+	return results, nil
+}
+
+// link returns the link of the given entry.
+// The profile template supports `{dn}`, `{uid}`, `{mail}` and `{cn}` placeholders.
+func (provider *Provider) link(e *entry) string {
+	uid := e.first("uid", "sAMAccountName")
+	if provider.profile == "" || (uid == "" && strings.Contains(provider.profile, "{uid}")) {
+		return fmt.Sprintf("%s://%s/%s", provider.url.Scheme, provider.url.Host, url.QueryEscape(e.dn))
+	}
+	return strings.NewReplacer(
+		"{dn}", url.QueryEscape(e.dn),
+		"{uid}", url.QueryEscape(uid),
+		"{mail}", url.QueryEscape(e.first("mail")),
+		"{cn}", url.QueryEscape(e.first("cn")),
+	).Replace(provider.profile)
+}
+
+// dial connects to the LDAP server
+func (provider *Provider) dial(ctx context.Context) (*conn, error) {
+	host := provider.url.Host
+	if provider.url.Port() == "" {
+		if provider.url.Scheme == "ldaps" {
+			host = net.JoinHostPort(provider.url.Hostname(), "636")
+		} else {
+			host = net.JoinHostPort(provider.url.Hostname(), "389")
+		}
+	}
+
+	d := net.Dialer{}
+	nc, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	// The deadline and the cancellation cover the TLS handshake as well
+	if dl, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(dl)
+	}
+	c := newConn(ctx, nc)
+	if provider.url.Scheme == "ldaps" {
+		tc := tls.Client(nc, &tls.Config{ServerName: provider.url.Hostname()})
+		if err := tc.Handshake(); err != nil {
+			close(c.done)
+			nc.Close()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		c.conn, c.reader = tc, bufio.NewReader(tc)
+	}
+
+	return c, nil
+}
+
+// newConn returns a new LDAP connection of the given network connection.
+// The network connection is closed when the context is done.
+func newConn(ctx context.Context, nc net.Conn) *conn {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			nc.Close()
+		case <-done:
+		}
+	}()
+	return &conn{conn: nc, reader: bufio.NewReader(nc), done: done}
+}
+
+// conn represents an LDAP connection
+type conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	done   chan struct{}
+	id     int
+}
+
+// entry represents an LDAP entry
+type entry struct {
+	dn    string
+	attrs map[string][]string
+}
+
+// first returns the first value of the first given attribute which exists
+func (e *entry) first(names ...string) string {
+	for _, n := range names {
+		for k, v := range e.attrs {
+			if strings.EqualFold(k, n) && len(v) > 0 {
+				return v[0]
+			}
+		}
+	}
+	return ""
+}
+
+// group checks whether the entry is a group or not
+func (e *entry) group() bool {
+	for k, v := range e.attrs {
+		if strings.EqualFold(k, "objectClass") {
+			for _, oc := range v {
+				switch strings.ToLower(oc) {
+				case "group", "groupofnames", "groupofuniquenames", "posixgroup":
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// send sends the given protocol operation and returns the message id
+func (c *conn) send(op *packet) (int, error) {
+	c.id++
+	m := newConstructed(tagSequence, newInt(tagInteger, c.id), op)
+	if _, err := c.conn.Write(m.bytes()); err != nil {
+		return 0, err
+	}
+	return c.id, nil
+}
+
+// receive receives the protocol operation of the next message
+func (c *conn) receive(id int) (*packet, error) {
+	for {
+		m, err := readPacket(c.reader)
+		if err != nil {
+			return nil, err
+		}
+		if len(m.children) < 2 {
+			return nil, errors.New("invalid LDAP message")
+		}
+		if m.children[0].int() == id {
+			return m.children[1], nil
+		}
+	}
+}
+
+// result checks the LDAP result of the given response
+func result(op *packet) error {
+	if len(op.children) < 3 {
+		return errors.New("invalid LDAP result")
+	}
+	// 0: success, 4: sizeLimitExceeded
+	if code := op.children[0].int(); code != 0 && code != 4 {
+		msg := op.children[2].str()
+		if msg == "" {
+			msg = fmt.Sprintf("result code %d", code)
+		}
+		return errors.New("ldap error: " + msg)
+	}
+	return nil
+}
+
+// bind makes a simple bind
+func (c *conn) bind(username, password string) error {
+	id, err := c.send(newConstructed(tagBindRequest,
+		newInt(tagInteger, 3),
+		newString(tagOctetString, username),
+		newString(tagAuthSimple, password),
+	))
+	if err != nil {
+		return err
+	}
+	op, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if op.tag != tagBindResponse {
+		return errors.New("unexpected LDAP bind response")
+	}
+	return result(op)
+}
+
+// search makes a subtree search
+func (c *conn) search(base string, filter *packet, sizeLimit int) ([]*entry, error) {
+	al := newConstructed(tagSequence)
+	for _, a := range attributes {
+		al.children = append(al.children, newString(tagOctetString, a))
+	}
+	id, err := c.send(newConstructed(tagSearchRequest,
+		newString(tagOctetString, base),
+		newInt(tagEnumerated, 2), // wholeSubtree
+		newInt(tagEnumerated, 0), // neverDerefAliases
+		newInt(tagInteger, sizeLimit),
+		newInt(tagInteger, 0),
+		newBool(false),
+		filter,
+		al,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*entry{}
+	for {
+		op, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+		switch op.tag {
+		case tagSearchResultEntry:
+			if len(op.children) < 2 {
+				continue
+			}
+			e := &entry{dn: op.children[0].str(), attrs: map[string][]string{}}
+			for _, a := range op.children[1].children {
+				if len(a.children) < 2 {
+					continue
+				}
+				for _, v := range a.children[1].children {
+					e.attrs[a.children[0].str()] = append(e.attrs[a.children[0].str()], v.str())
+				}
+			}
+			entries = append(entries, e)
+		case tagSearchResultDone:
+			return entries, result(op)
+		}
+	}
+}
+
+// close unbinds and closes the connection
+func (c *conn) close() {
+	c.send(&packet{tag: tagUnbindRequest})
+	close(c.done)
+	c.conn.Close()
+}
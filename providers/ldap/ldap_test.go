@@ -0,0 +1,137 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package ldap
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// fakeServer serves the LDAP messages of the given connection by the given handler
+func fakeServer(t *testing.T, nc net.Conn, handle func(id int, op *packet) []*packet) {
+	r := bufio.NewReader(nc)
+	for {
+		m, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		if len(m.children) < 2 {
+			t.Errorf("invalid LDAP message %+v", m)
+			return
+		}
+		id := m.children[0].int()
+		for _, op := range handle(id, m.children[1]) {
+			if _, err := nc.Write(newConstructed(tagSequence, newInt(tagInteger, id), op).bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ldapResult returns an LDAP result of the given tag
+func ldapResult(tag byte, code int, msg string) *packet {
+	return newConstructed(tag, newInt(tagEnumerated, code), newString(tagOctetString, ""), newString(tagOctetString, msg))
+}
+
+func TestBindAndSearch(t *testing.T) {
+	cc, sc := net.Pipe()
+	go fakeServer(t, sc, func(id int, op *packet) []*packet {
+		switch op.tag {
+		case tagBindRequest:
+			if op.children[1].str() != "cn=admin" || op.children[2].str() != "secret" {
+				return []*packet{ldapResult(tagBindResponse, 49, "invalid credentials")}
+			}
+			return []*packet{ldapResult(tagBindResponse, 0, "")}
+		case tagSearchRequest:
+			if op.children[0].str() != "dc=example,dc=com" || op.children[3].int() != 10 {
+				t.Errorf("unexpected search request %+v", op)
+			}
+			if f := op.children[6]; f.tag != 0xa3 || f.children[1].str() != "jane" {
+				t.Errorf("unexpected search filter %+v", f)
+			}
+			return []*packet{
+				newConstructed(tagSearchResultEntry,
+					newString(tagOctetString, "uid=jane,dc=example,dc=com"),
+					newConstructed(tagSequence,
+						newConstructed(tagSequence,
+							newString(tagOctetString, "cn"),
+							newConstructed(tagSet, newString(tagOctetString, "Jane Doe")),
+						),
+						newConstructed(tagSequence,
+							newString(tagOctetString, "objectClass"),
+							newConstructed(tagSet, newString(tagOctetString, "top"), newString(tagOctetString, "person")),
+						),
+					),
+				),
+				ldapResult(tagSearchResultDone, 4, ""),
+			}
+		}
+		return nil
+	})
+
+	c := newConn(context.Background(), cc)
+	defer c.close()
+	if err := c.bind("cn=admin", "wrong"); err == nil || err.Error() != "ldap error: invalid credentials" {
+		t.Fatalf("expected a bind error, got %v", err)
+	}
+	if err := c.bind("cn=admin", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	f, err := parseFilter("(uid=jane)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	el, err := c.search("dc=example,dc=com", f, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(el) != 1 || el[0].dn != "uid=jane,dc=example,dc=com" || el[0].first("CN") != "Jane Doe" || el[0].group() {
+		t.Fatalf("unexpected entries %+v", el)
+	}
+	if oc := el[0].attrs["objectClass"]; len(oc) != 2 || oc[1] != "person" {
+		t.Errorf("unexpected object classes %v", oc)
+	}
+}
+
+func TestSearchError(t *testing.T) {
+	cc, sc := net.Pipe()
+	go fakeServer(t, sc, func(id int, op *packet) []*packet {
+		return []*packet{ldapResult(tagSearchResultDone, 32, "no such object")}
+	})
+
+	c := newConn(context.Background(), cc)
+	defer c.close()
+	f, _ := parseFilter("(uid=jane)")
+	if _, err := c.search("dc=example,dc=com", f, 10); err == nil || err.Error() != "ldap error: no such object" {
+		t.Fatalf("expected a search error, got %v", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	cc, sc := net.Pipe()
+	defer sc.Close()
+	// The server never responds
+	go fakeServer(t, sc, func(id int, op *packet) []*packet { return nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c := newConn(ctx, cc)
+	errc := make(chan error, 1)
+	go func() { errc <- c.bind("cn=admin", "secret") }()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the connection to be closed by the context")
+	}
+}
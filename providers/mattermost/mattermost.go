@@ -0,0 +1,354 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package mattermost implements Mattermost provider
+package mattermost
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yieldbot/ferret/providers/chat"
+	"github.com/yieldbot/ferret/providers/text"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// maxPerPage is the maximum page size allowed by the Mattermost API
+const maxPerPage = 200
+
+// Register registers the provider
+func Register(config map[string]interface{}, f func(interface{}) error) {
+
+	name, ok := config["Name"].(string)
+	if name == "" || !ok {
+		name = "mattermost"
+	}
+	title, ok := config["Title"].(string)
+	if title == "" || !ok {
+		title = "Mattermost"
+	}
+	priority, ok := config["Priority"].(int64)
+	if priority == 0 || !ok {
+		priority = 500
+	}
+	u, _ := config["URL"].(string)
+	token, _ := config["Token"].(string)
+	teams, _ := config["Teams"].([]string)
+	channels, _ := config["Channels"].([]string)
+	query, _ := config["Query"].(string)
+	rewrite, _ := config["Rewrite"].(string)
+
+	p := Provider{
+		provider: "mattermost",
+		name:     name,
+		title:    title,
+		priority: priority,
+		url:      strings.TrimSuffix(u, "/"),
+		token:    token,
+		teams:    teams,
+		channels: channels,
+		query:    query,
+		rewrite:  rewrite,
+		teamIDs:  map[string]*SRTeam{},
+		chans:    map[string]*SRChannel{},
+		users:    map[string]string{},
+	}
+	if p.url != "" && p.token != "" {
+		p.enabled = true
+	}
+
+	if err := f(&p); err != nil {
+		panic(err)
+	}
+}
+
+// Provider represents the provider
+type Provider struct {
+	provider string
+	enabled  bool
+	name     string
+	title    string
+	priority int64
+	url      string
+	token    string
+	teams    []string
+	channels []string
+	query    string
+	rewrite  string
+
+	mu      sync.Mutex
+	teamIDs map[string]*SRTeam
+	chans   map[string]*SRChannel
+	users   map[string]string
+}
+
+// Search makes a search
+func (provider *Provider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+
+	results := []map[string]interface{}{}
+	page, ok := args["page"].(int)
+	if page < 1 || !ok {
+		page = 1
+	}
+	limit, ok := args["limit"].(int)
+	if limit < 1 || !ok {
+		limit = 10
+	}
+	keyword, ok := args["keyword"].(string)
+
+	teams, err := provider.searchTeams(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Channels are scoped by the `in:` search modifier
+	terms := keyword
+	for _, v := range provider.channels {
+		terms += " in:" + strings.TrimPrefix(strings.TrimPrefix(v, "~"), "#")
+	}
+	if provider.query != "" {
+		terms += " " + provider.query
+	}
+
+	// The posts of all the teams are merged so the first page*limit posts of
+	// each team are fetched by the upstream pages of up to maxPerPage posts
+	n := page * limit
+	perPage := n
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	var posts []*SRPosts
+	postTeams := map[string]*SRTeam{}
+	for _, t := range teams {
+		for up := 0; up*perPage < n; up++ {
+			var sr SearchResult
+			body := map[string]interface{}{"terms": terms, "is_or_search": false, "page": up, "per_page": perPage}
+			if err := provider.do(ctx, "POST", fmt.Sprintf("%s/api/v4/teams/%s/posts/search", provider.url, t.ID), body, &sr); err != nil {
+				return nil, err
+			}
+			for _, id := range sr.Order {
+				if v, ok := sr.Posts[id]; ok {
+					posts = append(posts, v)
+					postTeams[v.ID] = t
+				}
+			}
+			if len(sr.Order) < perPage {
+				break
+			}
+		}
+	}
+	sort.Sort(byDate(posts))
+
+	var l, h = (page - 1) * limit, page * limit
+	if l > len(posts) {
+		l = len(posts)
+	}
+	if h > len(posts) {
+		h = len(posts)
+	}
+	posts = posts[l:h]
+
+	var uids []string
+	for _, v := range posts {
+		uids = append(uids, v.UserID)
+	}
+	users, err := provider.usernames(ctx, uids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range posts {
+		cn := v.ChannelID
+		if c, err := provider.channel(ctx, v.ChannelID); err == nil {
+			cn = c.Name
+		}
+		ri := map[string]interface{}{
+			"Link":        fmt.Sprintf("%s/%s/pl/%s", provider.url, postTeams[v.ID].Name, v.ID),
+			"Title":       fmt.Sprintf("@%s in #%s", users[v.UserID], cn),
+			"Description": text.Snippet(chat.Render(v.Message), keyword),
+			"Date":        time.Unix(0, v.CreateAt*int64(time.Millisecond)),
+		}
+		results = append(results, ri)
+	}
+
+	return results, nil
+}
+
+// searchTeams returns the teams which are searched.
+// All the teams of the user are searched when there is no team configured.
+func (provider *Provider) searchTeams(ctx context.Context) ([]*SRTeam, error) {
+	if len(provider.teams) == 0 {
+		var teams []*SRTeam
+		if err := provider.do(ctx, "GET", provider.url+"/api/v4/users/me/teams", nil, &teams); err != nil {
+			return nil, err
+		}
+		return teams, nil
+	}
+
+	var teams []*SRTeam
+	for _, v := range provider.teams {
+		provider.mu.Lock()
+		t, ok := provider.teamIDs[v]
+		provider.mu.Unlock()
+		if !ok {
+			t = &SRTeam{}
+			if err := provider.do(ctx, "GET", fmt.Sprintf("%s/api/v4/teams/name/%s", provider.url, url.QueryEscape(v)), nil, t); err != nil {
+				return nil, err
+			}
+			provider.mu.Lock()
+			provider.teamIDs[v] = t
+			provider.mu.Unlock()
+		}
+		teams = append(teams, t)
+	}
+	return teams, nil
+}
+
+// channel returns the given channel by using a cache
+func (provider *Provider) channel(ctx context.Context, id string) (*SRChannel, error) {
+	provider.mu.Lock()
+	c, ok := provider.chans[id]
+	provider.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+
+	c = &SRChannel{}
+	if err := provider.do(ctx, "GET", fmt.Sprintf("%s/api/v4/channels/%s", provider.url, id), nil, c); err != nil {
+		return nil, err
+	}
+	provider.mu.Lock()
+	provider.chans[id] = c
+	provider.mu.Unlock()
+	return c, nil
+}
+
+// usernames returns the usernames of the given user ids by using a cache
+func (provider *Provider) usernames(ctx context.Context, ids []string) (map[string]string, error) {
+	users := map[string]string{}
+	var missing []string
+	provider.mu.Lock()
+	for _, id := range ids {
+		if n, ok := provider.users[id]; ok {
+			users[id] = n
+		} else if _, ok := users[id]; !ok {
+			users[id] = id
+			missing = append(missing, id)
+		}
+	}
+	provider.mu.Unlock()
+	if len(missing) == 0 {
+		return users, nil
+	}
+
+	var ul []*SRUser
+	if err := provider.do(ctx, "POST", provider.url+"/api/v4/users/ids", missing, &ul); err != nil {
+		return nil, err
+	}
+	provider.mu.Lock()
+	for _, v := range ul {
+		provider.users[v.ID] = v.Username
+		users[v.ID] = v.Username
+	}
+	provider.mu.Unlock()
+	return users, nil
+}
+
+// Probe checks the reachability of the provider by the current user endpoint
+func (provider *Provider) Probe(ctx context.Context) error {
+	var v map[string]interface{}
+	return provider.do(ctx, "GET", provider.url+"/api/v4/users/me", nil, &v)
+}
+
+// do makes a request to the given URL and unmarshals the response into v
+func (provider *Provider) do(ctx context.Context, method, u string, body, v interface{}) error {
+	var rb io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return errors.New("failed to marshal JSON data. Error: " + err.Error())
+		}
+		rb = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u, rb)
+	if err != nil {
+		return errors.New("failed to prepare request. Error: " + err.Error())
+	}
+	req.Header.Set("Authorization", "Bearer "+provider.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return err
+	} else if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+	}
+	return nil
+}
+
+// byDate implements sort.Interface for sorting the posts by date, newest first
+type byDate []*SRPosts
+
+func (p byDate) Len() int           { return len(p) }
+func (p byDate) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byDate) Less(i, j int) bool { return p[i].CreateAt > p[j].CreateAt }
+
+// SearchResult represents the structure of the search result
+type SearchResult struct {
+	Order []string            `json:"order"`
+	Posts map[string]*SRPosts `json:"posts"`
+}
+
+// SRPosts represents the structure of the search result posts
+type SRPosts struct {
+	ID        string `json:"id"`
+	CreateAt  int64  `json:"create_at"`
+	UserID    string `json:"user_id"`
+	ChannelID string `json:"channel_id"`
+	Message   string `json:"message"`
+}
+
+// SRTeam represents the structure of a team
+type SRTeam struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// SRChannel represents the structure of a channel
+type SRChannel struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// SRUser represents the structure of a user
+type SRUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
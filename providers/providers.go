@@ -9,9 +9,24 @@ package providers
 
 import (
 	"github.com/yieldbot/ferret/providers/answerhub"
+	"github.com/yieldbot/ferret/providers/confluence"
 	"github.com/yieldbot/ferret/providers/consul"
+	"github.com/yieldbot/ferret/providers/discourse"
+	"github.com/yieldbot/ferret/providers/elasticsearch"
+	"github.com/yieldbot/ferret/providers/feed"
+	"github.com/yieldbot/ferret/providers/files"
 	"github.com/yieldbot/ferret/providers/github"
+	"github.com/yieldbot/ferret/providers/gitlab"
+	"github.com/yieldbot/ferret/providers/jenkins"
+	"github.com/yieldbot/ferret/providers/jira"
+	"github.com/yieldbot/ferret/providers/kubernetes"
+	"github.com/yieldbot/ferret/providers/ldap"
+	"github.com/yieldbot/ferret/providers/mattermost"
+	"github.com/yieldbot/ferret/providers/registry"
+	"github.com/yieldbot/ferret/providers/rocketchat"
 	"github.com/yieldbot/ferret/providers/slack"
+	"github.com/yieldbot/ferret/providers/sql"
+	"github.com/yieldbot/ferret/providers/stackexchange"
 	"github.com/yieldbot/ferret/providers/trello"
 )
 
@@ -26,12 +41,42 @@ func Register(args []map[string]interface{}, f func(interface{}) error) {
 		switch p {
 		case "answerhub":
 			answerhub.Register(v, f)
+		case "confluence":
+			confluence.Register(v, f)
 		case "consul":
 			consul.Register(v, f)
+		case "discourse":
+			discourse.Register(v, f)
+		case "elasticsearch":
+			elasticsearch.Register(v, f)
+		case "feed":
+			feed.Register(v, f)
+		case "files":
+			files.Register(v, f)
 		case "github":
 			github.Register(v, f)
+		case "gitlab":
+			gitlab.Register(v, f)
+		case "jenkins":
+			jenkins.Register(v, f)
+		case "jira":
+			jira.Register(v, f)
+		case "kubernetes":
+			kubernetes.Register(v, f)
+		case "ldap":
+			ldap.Register(v, f)
+		case "mattermost":
+			mattermost.Register(v, f)
+		case "registry":
+			registry.Register(v, f)
+		case "rocketchat":
+			rocketchat.Register(v, f)
 		case "slack":
 			slack.Register(v, f)
+		case "sql":
+			sql.Register(v, f)
+		case "stackexchange":
+			stackexchange.Register(v, f)
 		case "trello":
 			trello.Register(v, f)
 		default:
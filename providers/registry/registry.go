@@ -0,0 +1,501 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package registry implements Docker Registry HTTP API v2 provider
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+var (
+	linkRe        = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+	challengeRe   = regexp.MustCompile(`(\w+)="([^"]*)"`)
+	manifestTypes = strings.Join([]string{
+		"application/vnd.docker.distribution.manifest.v2+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.oci.image.index.v1+json",
+	}, ", ")
+)
+
+// Register registers the provider
+func Register(config map[string]interface{}, f func(interface{}) error) {
+
+	name, ok := config["Name"].(string)
+	if name == "" || !ok {
+		name = "registry"
+	}
+	title, ok := config["Title"].(string)
+	if title == "" || !ok {
+		title = "Registry"
+	}
+	priority, ok := config["Priority"].(int64)
+	if priority == 0 || !ok {
+		priority = 600
+	}
+	u, _ := config["URL"].(string)
+	username, _ := config["Username"].(string)
+	password, _ := config["Password"].(string)
+	token, _ := config["Token"].(string)
+	repositories, _ := config["Repositories"].([]string)
+	dashboard, _ := config["Dashboard"].(string)
+	refresh := 5 * time.Minute
+	if r, ok := config["Refresh"].(string); ok && r != "" {
+		d, err := time.ParseDuration(r)
+		if err != nil {
+			panic("invalid registry refresh interval: " + r)
+		}
+		refresh = d
+	}
+	rewrite, _ := config["Rewrite"].(string)
+
+	p := Provider{
+		provider:     "registry",
+		name:         name,
+		title:        title,
+		priority:     priority,
+		url:          strings.TrimSuffix(u, "/"),
+		username:     username,
+		password:     password,
+		token:        token,
+		repositories: repositories,
+		dashboard:    dashboard,
+		refresh:      refresh,
+		rewrite:      rewrite,
+		tags:         map[string]*tagList{},
+		tokens:       map[string]string{},
+	}
+	if p.url != "" {
+		p.enabled = true
+	}
+
+	if err := f(&p); err != nil {
+		panic(err)
+	}
+}
+
+// Provider represents the provider
+type Provider struct {
+	provider     string
+	enabled      bool
+	name         string
+	title        string
+	priority     int64
+	url          string
+	username     string
+	password     string
+	token        string
+	repositories []string
+	dashboard    string
+	refresh      time.Duration
+	rewrite      string
+
+	mu      sync.Mutex
+	catalog []string
+	fetched time.Time
+	tags    map[string]*tagList
+	tokens  map[string]string
+}
+
+// tagList represents the cached tags of a repository
+type tagList struct {
+	tags    []string
+	fetched time.Time
+}
+
+// image represents a matching repository tag
+type image struct {
+	repo string
+	tag  string
+}
+
+// Search makes a search
+func (provider *Provider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+
+	results := []map[string]interface{}{}
+	page, ok := args["page"].(int)
+	if page < 1 || !ok {
+		page = 1
+	}
+	limit, ok := args["limit"].(int)
+	if limit < 1 || !ok {
+		limit = 10
+	}
+	keyword, ok := args["keyword"].(string)
+
+	repos, err := provider.repositoryList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// `repo:tag` keywords are split so both parts are matched
+	words := strings.Fields(strings.ToLower(strings.Replace(keyword, ":", " ", -1)))
+	var images []*image
+	for _, r := range repos {
+		lr := strings.ToLower(r)
+		if len(words) > 0 && !matchAny(lr, words) {
+			continue
+		}
+		tags, err := provider.tagList(ctx, r)
+		if err != nil {
+			if err == context.DeadlineExceeded || err == context.Canceled {
+				return nil, err
+			}
+			continue
+		}
+		// Registries list the tags in ascending order so the newer ones come first
+		for i := len(tags) - 1; i >= 0; i-- {
+			if matchAll(lr+":"+strings.ToLower(tags[i]), words) {
+				images = append(images, &image{repo: r, tag: tags[i]})
+			}
+		}
+	}
+	args["total"] = len(images)
+
+	var l, h = (page - 1) * limit, page * limit
+	if l > len(images) {
+		l = len(images)
+	}
+	if h > len(images) {
+		h = len(images)
+	}
+
+	for _, v := range images[l:h] {
+		var d string
+		var t time.Time
+		digest, created, platforms, err := provider.manifest(ctx, v.repo, v.tag)
+		if err != nil {
+			if err == context.DeadlineExceeded || err == context.Canceled {
+				return nil, err
+			}
+			d = "failed to fetch the manifest. Error: " + err.Error()
+		} else {
+			d = digest
+			if platforms != "" {
+				d += " - " + platforms
+			}
+			t = created
+		}
+
+		link := fmt.Sprintf("%s/v2/%s/manifests/%s", provider.url, v.repo, v.tag)
+		if provider.dashboard != "" {
+			link = strings.NewReplacer("{repo}", v.repo, "{tag}", url.QueryEscape(v.tag), "{digest}", digest).Replace(provider.dashboard)
+		}
+		ri := map[string]interface{}{
+			"Link":        link,
+			"Title":       v.repo + ":" + v.tag,
+			"Description": d,
+			"Date":        t,
+		}
+		results = append(results, ri)
+	}
+
+	return results, nil
+}
+
+// repositoryList returns the configured repositories or the catalog by using a cache
+func (provider *Provider) repositoryList(ctx context.Context) ([]string, error) {
+	if len(provider.repositories) > 0 {
+		return provider.repositories, nil
+	}
+
+	provider.mu.Lock()
+	catalog, fetched := provider.catalog, provider.fetched
+	provider.mu.Unlock()
+	if catalog != nil && time.Since(fetched) < provider.refresh {
+		return catalog, nil
+	}
+
+	catalog = []string{}
+	u := provider.url + "/v2/_catalog?n=1000"
+	for u != "" {
+		var cr CatalogResult
+		h, err := provider.get(ctx, u, "", "registry:catalog:*", &cr)
+		if err != nil {
+			return nil, err
+		}
+		catalog = append(catalog, cr.Repositories...)
+		u = nextLink(provider.url, h.Get("Link"))
+	}
+
+	provider.mu.Lock()
+	provider.catalog = catalog
+	provider.fetched = time.Now()
+	provider.mu.Unlock()
+	return catalog, nil
+}
+
+// tagList returns the tags of the given repository by using a cache
+func (provider *Provider) tagList(ctx context.Context, repo string) ([]string, error) {
+	provider.mu.Lock()
+	tl, ok := provider.tags[repo]
+	provider.mu.Unlock()
+	if ok && time.Since(tl.fetched) < provider.refresh {
+		return tl.tags, nil
+	}
+
+	tags := []string{}
+	u := fmt.Sprintf("%s/v2/%s/tags/list?n=1000", provider.url, repo)
+	for u != "" {
+		var tr TagsResult
+		h, err := provider.get(ctx, u, "", "repository:"+repo+":pull", &tr)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tr.Tags...)
+		u = nextLink(provider.url, h.Get("Link"))
+	}
+
+	provider.mu.Lock()
+	provider.tags[repo] = &tagList{tags: tags, fetched: time.Now()}
+	provider.mu.Unlock()
+	return tags, nil
+}
+
+// manifest returns the digest, the creation date and the platforms of the given image.
+// The creation date is read from the image configuration.
+func (provider *Provider) manifest(ctx context.Context, repo, tag string) (string, time.Time, string, error) {
+	var t time.Time
+	scope := "repository:" + repo + ":pull"
+
+	var mr ManifestResult
+	h, err := provider.get(ctx, fmt.Sprintf("%s/v2/%s/manifests/%s", provider.url, repo, tag), manifestTypes, scope, &mr)
+	if err != nil {
+		return "", t, "", err
+	}
+	digest := h.Get("Docker-Content-Digest")
+
+	// Multi-platform images refer to a manifest per platform
+	// so the configuration of the first platform is used
+	var platforms []string
+	if len(mr.Manifests) > 0 {
+		var pd string
+		for _, v := range mr.Manifests {
+			if v.Platform != nil && v.Platform.OS != "unknown" {
+				platforms = append(platforms, v.Platform.OS+"/"+v.Platform.Architecture)
+				if pd == "" {
+					pd = v.Digest
+				}
+			}
+		}
+		mr = ManifestResult{}
+		if pd == "" {
+			return digest, t, strings.Join(platforms, ", "), nil
+		}
+		if _, err := provider.get(ctx, fmt.Sprintf("%s/v2/%s/manifests/%s", provider.url, repo, pd), manifestTypes, scope, &mr); err != nil {
+			return digest, t, strings.Join(platforms, ", "), nil
+		}
+	}
+
+	if mr.Config != nil && mr.Config.Digest != "" {
+		var ic ImageConfig
+		if _, err := provider.get(ctx, fmt.Sprintf("%s/v2/%s/blobs/%s", provider.url, repo, mr.Config.Digest), "", scope, &ic); err == nil {
+			t = ic.Created
+		}
+	}
+	return digest, t, strings.Join(platforms, ", "), nil
+}
+
+// get makes a GET request to the given URL and unmarshals the response into v.
+// Bearer tokens are requested from the authorization server of the registry when it's required.
+func (provider *Provider) get(ctx context.Context, u, accept, scope string, v interface{}) (http.Header, error) {
+	for i := 0; ; i++ {
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, errors.New("failed to prepare request. Error: " + err.Error())
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		provider.mu.Lock()
+		token := provider.tokens[scope]
+		provider.mu.Unlock()
+		switch {
+		case token != "":
+			req.Header.Set("Authorization", "Bearer "+token)
+		case provider.token != "":
+			req.Header.Set("Authorization", "Bearer "+provider.token)
+		case provider.username != "" || provider.password != "":
+			req.SetBasicAuth(provider.username, provider.password)
+		}
+
+		res, err := ctxhttp.Do(ctx, nil, req)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode == http.StatusUnauthorized && i == 0 {
+			res.Body.Close()
+			c := res.Header.Get("WWW-Authenticate")
+			if !strings.HasPrefix(strings.ToLower(c), "bearer ") {
+				return nil, errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+			}
+			if err := provider.authorize(ctx, c, scope); err != nil {
+				return nil, err
+			}
+			continue
+		} else if res.StatusCode < 200 || res.StatusCode > 299 {
+			res.Body.Close()
+			return nil, errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+		}
+		defer res.Body.Close()
+		data, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return nil, errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+		}
+		return res.Header, nil
+	}
+}
+
+// authorize requests a token for the given scope by using the given Bearer challenge
+func (provider *Provider) authorize(ctx context.Context, challenge, scope string) error {
+	params := map[string]string{}
+	for _, m := range challengeRe.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+	if params["realm"] == "" {
+		return errors.New("invalid registry authentication challenge: " + challenge)
+	}
+	if params["scope"] != "" {
+		scope = params["scope"]
+	}
+
+	q := url.Values{}
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	q.Set("scope", scope)
+	u := params["realm"] + "?" + q.Encode()
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return errors.New("failed to prepare request. Error: " + err.Error())
+	}
+	if provider.username != "" || provider.password != "" {
+		req.SetBasicAuth(provider.username, provider.password)
+	}
+
+	res, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return err
+	} else if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	var tr TokenResult
+	if err := json.Unmarshal(data, &tr); err != nil {
+		return errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+	}
+	token := tr.Token
+	if token == "" {
+		token = tr.AccessToken
+	}
+	if token == "" {
+		return errors.New("missing registry token")
+	}
+
+	provider.mu.Lock()
+	provider.tokens[scope] = token
+	provider.mu.Unlock()
+	return nil
+}
+
+// nextLink returns the next page URL of the given Link header
+func nextLink(base, link string) string {
+	m := linkRe.FindStringSubmatch(link)
+	if m == nil {
+		return ""
+	}
+	if strings.HasPrefix(m[1], "/") {
+		return base + m[1]
+	}
+	return m[1]
+}
+
+// matchAny checks whether the given text contains any of the words or not
+func matchAny(s string, words []string) bool {
+	for _, w := range words {
+		if strings.Contains(s, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAll checks whether the given text contains all the words or not
+func matchAll(s string, words []string) bool {
+	for _, w := range words {
+		if !strings.Contains(s, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// CatalogResult represents the structure of the catalog
+type CatalogResult struct {
+	Repositories []string `json:"repositories"`
+}
+
+// TagsResult represents the structure of the tags list
+type TagsResult struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// ManifestResult represents the structure of an image manifest or manifest list
+type ManifestResult struct {
+	MediaType string         `json:"mediaType"`
+	Config    *MRConfig      `json:"config"`
+	Manifests []*MRManifests `json:"manifests"`
+}
+
+// MRConfig represents the structure of the manifest config field
+type MRConfig struct {
+	Digest string `json:"digest"`
+}
+
+// MRManifests represents the structure of the manifest list manifests
+type MRManifests struct {
+	Digest   string       `json:"digest"`
+	Platform *MRMPlatform `json:"platform"`
+}
+
+// MRMPlatform represents the structure of the manifest list manifests platform field
+type MRMPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+// ImageConfig represents the structure of the image configuration
+type ImageConfig struct {
+	Created time.Time `json:"created"`
+}
+
+// TokenResult represents the structure of the token response
+type TokenResult struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+}
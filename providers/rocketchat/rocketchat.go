@@ -0,0 +1,307 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package rocketchat implements Rocket.Chat provider
+package rocketchat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yieldbot/ferret/providers/chat"
+	"github.com/yieldbot/ferret/providers/text"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// Register registers the provider
+func Register(config map[string]interface{}, f func(interface{}) error) {
+
+	name, ok := config["Name"].(string)
+	if name == "" || !ok {
+		name = "rocketchat"
+	}
+	title, ok := config["Title"].(string)
+	if title == "" || !ok {
+		title = "Rocket.Chat"
+	}
+	priority, ok := config["Priority"].(int64)
+	if priority == 0 || !ok {
+		priority = 500
+	}
+	u, _ := config["URL"].(string)
+	username, _ := config["Username"].(string)
+	token, _ := config["Token"].(string)
+	teams, _ := config["Teams"].([]string)
+	channels, _ := config["Channels"].([]string)
+	refresh := 5 * time.Minute
+	if r, ok := config["Refresh"].(string); ok && r != "" {
+		d, err := time.ParseDuration(r)
+		if err != nil {
+			panic("invalid rocketchat refresh interval: " + r)
+		}
+		refresh = d
+	}
+	rewrite, _ := config["Rewrite"].(string)
+
+	p := Provider{
+		provider: "rocketchat",
+		name:     name,
+		title:    title,
+		priority: priority,
+		url:      strings.TrimSuffix(u, "/"),
+		userID:   username,
+		token:    token,
+		teams:    teams,
+		channels: channels,
+		refresh:  refresh,
+		rewrite:  rewrite,
+	}
+	if p.url != "" && p.userID != "" && p.token != "" {
+		p.enabled = true
+	}
+
+	if err := f(&p); err != nil {
+		panic(err)
+	}
+}
+
+// Provider represents the provider
+type Provider struct {
+	provider string
+	enabled  bool
+	name     string
+	title    string
+	priority int64
+	url      string
+	userID   string
+	token    string
+	teams    []string
+	channels []string
+	refresh  time.Duration
+	rewrite  string
+
+	mu       sync.Mutex
+	rooms    []*SRRoom
+	fetched  time.Time
+	fetching bool
+}
+
+// Search makes a search
+func (provider *Provider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+
+	results := []map[string]interface{}{}
+	page, ok := args["page"].(int)
+	if page < 1 || !ok {
+		page = 1
+	}
+	limit, ok := args["limit"].(int)
+	if limit < 1 || !ok {
+		limit = 10
+	}
+	keyword, ok := args["keyword"].(string)
+
+	rooms, err := provider.searchRooms(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rocket.Chat searches a room at a time so the messages of all the rooms
+	// are merged and the first pages are fetched at once
+	var messages []*SRMessages
+	roomsByID := map[string]*SRRoom{}
+	for _, r := range rooms {
+		roomsByID[r.ID] = r
+		var sr SearchResult
+		u := fmt.Sprintf("%s/api/v1/chat.search?roomId=%s&searchText=%s&count=%d&offset=0", provider.url, url.QueryEscape(r.ID), url.QueryEscape(keyword), page*limit)
+		if err := provider.get(ctx, u, &sr); err != nil {
+			return nil, err
+		}
+		messages = append(messages, sr.Messages...)
+	}
+	sort.Sort(byDate(messages))
+
+	var l, h = (page - 1) * limit, page * limit
+	if l > len(messages) {
+		l = len(messages)
+	}
+	if h > len(messages) {
+		h = len(messages)
+	}
+
+	for _, v := range messages[l:h] {
+		r := roomsByID[v.RoomID]
+		if r == nil {
+			r = &SRRoom{ID: v.RoomID, Name: v.RoomID}
+		}
+		var un string
+		if v.User != nil {
+			un = v.User.Username
+		}
+		ri := map[string]interface{}{
+			"Link":        fmt.Sprintf("%s/%s/%s?msg=%s", provider.url, roomPath(r.Type), url.QueryEscape(r.Name), url.QueryEscape(v.ID)),
+			"Title":       fmt.Sprintf("@%s in #%s", un, r.Name),
+			"Description": text.Snippet(chat.Render(v.Msg), keyword),
+			"Date":        v.Ts,
+		}
+		results = append(results, ri)
+	}
+
+	return results, nil
+}
+
+// searchRooms returns the rooms which are searched by using a cache.
+// The room list is fetched without holding the lock and the other searches use
+// the current list meanwhile.
+func (provider *Provider) searchRooms(ctx context.Context) ([]*SRRoom, error) {
+
+	// Claim the refresh
+	provider.mu.Lock()
+	if provider.rooms != nil && (provider.fetching || time.Since(provider.fetched) < provider.refresh) {
+		rooms := provider.rooms
+		provider.mu.Unlock()
+		return rooms, nil
+	}
+	provider.fetching = true
+	provider.mu.Unlock()
+
+	rooms, err := provider.fetchRooms(ctx)
+
+	// Swap the rooms in
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	provider.fetching = false
+	if err != nil {
+		return nil, err
+	}
+	provider.rooms = rooms
+	provider.fetched = time.Now()
+	return rooms, nil
+}
+
+// fetchRooms fetches the rooms which are searched.
+// The rooms of the configured channels and teams are searched if there is
+// any, otherwise all the rooms of the user are searched.
+func (provider *Provider) fetchRooms(ctx context.Context) ([]*SRRoom, error) {
+	rooms := []*SRRoom{}
+	if len(provider.channels) == 0 && len(provider.teams) == 0 {
+		var rr SRRooms
+		if err := provider.get(ctx, provider.url+"/api/v1/rooms.get", &rr); err != nil {
+			return nil, err
+		}
+		for _, v := range rr.Update {
+			if v.Name != "" {
+				rooms = append(rooms, v)
+			}
+		}
+		return rooms, nil
+	}
+
+	for _, v := range provider.channels {
+		var rr SRRooms
+		if err := provider.get(ctx, fmt.Sprintf("%s/api/v1/rooms.info?roomName=%s", provider.url, url.QueryEscape(strings.TrimPrefix(v, "#"))), &rr); err != nil {
+			return nil, err
+		}
+		if rr.Room != nil {
+			rooms = append(rooms, rr.Room)
+		}
+	}
+	for _, v := range provider.teams {
+		var rr SRRooms
+		if err := provider.get(ctx, fmt.Sprintf("%s/api/v1/teams.listRooms?teamName=%s&count=0", provider.url, url.QueryEscape(v)), &rr); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, rr.Rooms...)
+	}
+	return rooms, nil
+}
+
+// get makes a GET request to the given URL and unmarshals the response into v
+func (provider *Provider) get(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return errors.New("failed to prepare request. Error: " + err.Error())
+	}
+	req.Header.Set("X-User-Id", provider.userID)
+	req.Header.Set("X-Auth-Token", provider.token)
+
+	res, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return err
+	} else if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+	}
+	return nil
+}
+
+// roomPath returns the URL path of the given room type
+func roomPath(t string) string {
+	switch t {
+	case "p":
+		return "group"
+	case "d":
+		return "direct"
+	}
+	return "channel"
+}
+
+// byDate implements sort.Interface for sorting the messages by date, newest first
+type byDate []*SRMessages
+
+func (m byDate) Len() int           { return len(m) }
+func (m byDate) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+func (m byDate) Less(i, j int) bool { return m[i].Ts.After(m[j].Ts) }
+
+// SearchResult represents the structure of the search result
+type SearchResult struct {
+	Success  bool          `json:"success"`
+	Messages []*SRMessages `json:"messages"`
+}
+
+// SRMessages represents the structure of the search result messages
+type SRMessages struct {
+	ID     string    `json:"_id"`
+	RoomID string    `json:"rid"`
+	Msg    string    `json:"msg"`
+	Ts     time.Time `json:"ts"`
+	User   *SRMUser  `json:"u"`
+}
+
+// SRMUser represents the structure of the search result messages user field
+type SRMUser struct {
+	Username string `json:"username"`
+}
+
+// SRRooms represents the structure of the room responses
+type SRRooms struct {
+	Room   *SRRoom   `json:"room"`
+	Rooms  []*SRRoom `json:"rooms"`
+	Update []*SRRoom `json:"update"`
+}
+
+// SRRoom represents the structure of a room
+type SRRoom struct {
+	ID   string `json:"_id"`
+	Name string `json:"name"`
+	Type string `json:"t"`
+}
@@ -15,9 +15,10 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
-	"strings"
 	"time"
 
+	"github.com/yieldbot/ferret/providers/chat"
+	"github.com/yieldbot/ferret/providers/text"
 	"golang.org/x/net/context"
 	"golang.org/x/net/context/ctxhttp"
 )
@@ -40,18 +41,22 @@ func Register(config map[string]interface{}, f func(interface{}) error) {
 	token, _ := config["Token"].(string)
 	query, _ := config["Query"].(string)
 	rewrite, _ := config["Rewrite"].(string)
+	delegated, _ := config["Delegated"].(bool)
+	fallback, _ := config["Fallback"].(bool)
 
 	p := Provider{
-		provider: "slack",
-		name:     name,
-		title:    title,
-		priority: priority,
-		url:      "https://slack.com/api",
-		token:    token,
-		query:    query,
-		rewrite:  rewrite,
-	}
-	if p.token != "" {
+		provider:  "slack",
+		name:      name,
+		title:     title,
+		priority:  priority,
+		url:       "https://slack.com/api",
+		token:     token,
+		query:     query,
+		rewrite:   rewrite,
+		delegated: delegated,
+		fallback:  fallback,
+	}
+	if p.token != "" || p.delegated {
 		p.enabled = true
 	}
 
@@ -62,15 +67,17 @@ func Register(config map[string]interface{}, f func(interface{}) error) {
 
 // Provider represents the provider
 type Provider struct {
-	provider string
-	enabled  bool
-	name     string
-	title    string
-	priority int64
-	url      string
-	token    string
-	query    string
-	rewrite  string
+	provider  string
+	enabled   bool
+	name      string
+	title     string
+	priority  int64
+	url       string
+	token     string
+	query     string
+	rewrite   string
+	delegated bool
+	fallback  bool
 }
 
 // Search makes a search
@@ -86,8 +93,12 @@ func (provider *Provider) Search(ctx context.Context, args map[string]interface{
 		limit = 10
 	}
 	keyword, ok := args["keyword"].(string)
+	token := provider.token
+	if t, ok := args["token"].(string); ok && t != "" {
+		token = t
+	}
 
-	u := fmt.Sprintf("%s/search.all?page=%d&count=%d&query=%s&token=%s", provider.url, page, limit, url.QueryEscape(keyword), provider.token)
+	u := fmt.Sprintf("%s/search.all?page=%d&count=%d&query=%s&token=%s", provider.url, page, limit, url.QueryEscape(keyword), token)
 	if provider.query != "" {
 		u += fmt.Sprintf("%s", provider.query)
 	}
@@ -113,11 +124,7 @@ func (provider *Provider) Search(ctx context.Context, args map[string]interface{
 	}
 	if sr.Messages != nil {
 		for _, v := range sr.Messages.Matches {
-			// TODO: Improve partial text (i.e. ... keyword ...)
-			d := strings.TrimSpace(v.Text)
-			if len(d) > 255 {
-				d = d[0:252] + "..."
-			}
+			d := text.Snippet(chat.Render(v.Text), keyword)
 
 			var t time.Time
 			if ts, err := strconv.ParseFloat(v.Ts, 64); err == nil {
@@ -137,6 +144,35 @@ func (provider *Provider) Search(ctx context.Context, args map[string]interface{
 	return results, err
 }
 
+// Probe checks the reachability of the provider by auth.test.
+// The shared token is verified too unless the provider only has delegated credentials.
+func (provider *Provider) Probe(ctx context.Context) error {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/auth.test?token=%s", provider.url, provider.token), nil)
+	if err != nil {
+		return errors.New("failed to prepare request. Error: " + err.Error())
+	}
+	res, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return err
+	} else if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	var ar AuthTestResult
+	if err := json.Unmarshal(data, &ar); err != nil {
+		return errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+	}
+	if provider.token != "" && !ar.Ok {
+		return errors.New("failed to authenticate due to " + ar.Error)
+	}
+	return nil
+}
+
 // SearchResult represents the structure of the search result
 type SearchResult struct {
 	Ok       bool        `json:"ok"`
@@ -165,3 +201,9 @@ type SRMMatches struct {
 type SRMMChannel struct {
 	Name string `json:"name"`
 }
+
+// AuthTestResult represents the structure of the auth.test result
+type AuthTestResult struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,15 @@
+//go:build mysql
+// +build mysql
+
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package sql
+
+import (
+	// For mysql driver
+	_ "github.com/go-sql-driver/mysql"
+)
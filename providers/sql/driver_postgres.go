@@ -0,0 +1,15 @@
+//go:build postgres
+// +build postgres
+
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package sql
+
+import (
+	// For postgres driver
+	_ "github.com/lib/pq"
+)
@@ -0,0 +1,15 @@
+//go:build sqlite3
+// +build sqlite3
+
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package sql
+
+import (
+	// For sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
@@ -0,0 +1,275 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package sql implements SQL database provider
+//
+// The database drivers are not linked by default. Build Ferret with the
+// `mysql`, `postgres` or `sqlite3` build tags for the bundled drivers.
+package sql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+var (
+	selectRe      = regexp.MustCompile(`(?is)^\s*(select|with)\s`)
+	placeholderRe = regexp.MustCompile(`\{(keyword|like|limit|offset)\}`)
+	limitRe       = regexp.MustCompile(`\{limit\}`)
+)
+
+// Register registers the provider
+func Register(config map[string]interface{}, f func(interface{}) error) {
+
+	name, ok := config["Name"].(string)
+	if name == "" || !ok {
+		name = "sql"
+	}
+	title, ok := config["Title"].(string)
+	if title == "" || !ok {
+		title = "SQL"
+	}
+	priority, ok := config["Priority"].(int64)
+	if priority == 0 || !ok {
+		priority = 300
+	}
+	driver, _ := config["Driver"].(string)
+	dsn, _ := config["DSN"].(string)
+	readOnly, _ := config["ReadOnly"].(bool)
+	template, _ := config["Template"].(string)
+	template = strings.TrimSuffix(strings.TrimSpace(template), ";")
+	if template != "" && (!selectRe.MatchString(template) || strings.Contains(template, ";")) {
+		panic("invalid sql template. It should be a single SELECT statement")
+	}
+	fields := map[string]string{
+		"link":        "link",
+		"title":       "title",
+		"description": "description",
+		"date":        "date",
+	}
+	if fm, ok := config["Fields"].(map[string]string); ok {
+		for k, v := range fm {
+			fields[k] = v
+		}
+	}
+	rewrite, _ := config["Rewrite"].(string)
+
+	p := Provider{
+		provider: "sql",
+		name:     name,
+		title:    title,
+		priority: priority,
+		driver:   driver,
+		readOnly: readOnly,
+		template: template,
+		fields:   fields,
+		rewrite:  rewrite,
+	}
+	if driver != "" && dsn != "" && template != "" {
+		if !linked(driver) {
+			switch driver {
+			case "mysql", "postgres", "sqlite3":
+				panic("sql driver " + driver + " is not linked. Build Ferret with the `" + driver + "` build tag (i.e. go build -tags " + driver + ")")
+			default:
+				panic("sql driver " + driver + " is not linked. The bundled drivers are mysql, postgres and sqlite3 and they are linked by the build tags of the same names")
+			}
+		}
+		db, err := sql.Open(driver, dsn)
+		if err != nil {
+			panic("failed to open sql database due to " + err.Error())
+		}
+		p.db = db
+		p.enabled = true
+	}
+
+	if err := f(&p); err != nil {
+		panic(err)
+	}
+}
+
+// Provider represents the provider
+type Provider struct {
+	provider string
+	enabled  bool
+	name     string
+	title    string
+	priority int64
+	driver   string
+	readOnly bool
+	template string
+	fields   map[string]string
+	rewrite  string
+	db       *sql.DB
+}
+
+// Search makes a search
+func (provider *Provider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+
+	results := []map[string]interface{}{}
+	page, ok := args["page"].(int)
+	if page < 1 || !ok {
+		page = 1
+	}
+	limit, ok := args["limit"].(int)
+	if limit < 1 || !ok {
+		limit = 10
+	}
+	keyword, ok := args["keyword"].(string)
+
+	if provider.db == nil {
+		return nil, errors.New("missing sql database configuration")
+	}
+	q, qa := provider.statement(keyword, limit, (page-1)*limit)
+
+	// Read-only transactions make sure that the template can't modify the data
+	var rows *sql.Rows
+	var tx *sql.Tx
+	var err error
+	if provider.readOnly {
+		tx, err = provider.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+		rows, err = tx.QueryContext(ctx, q, qa...)
+	} else {
+		rows, err = provider.db.QueryContext(ctx, q, qa...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		vals := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := map[string]interface{}{}
+		for i, c := range cols {
+			row[c] = vals[i]
+		}
+
+		d := strings.TrimSpace(toString(row[provider.fields["description"]]))
+		if len(d) > 255 {
+			d = d[0:252] + "..."
+		}
+
+		ri := map[string]interface{}{
+			"Link":        toString(row[provider.fields["link"]]),
+			"Title":       toString(row[provider.fields["title"]]),
+			"Description": d,
+			"Date":        toTime(row[provider.fields["date"]]),
+		}
+		results = append(results, ri)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// linked checks whether the given database driver is linked or not
+func linked(driver string) bool {
+	for _, v := range sql.Drivers() {
+		if v == driver {
+			return true
+		}
+	}
+	return false
+}
+
+// statement returns the SQL statement and its arguments for the template.
+// The template placeholders (`{keyword}`, `{like}`, `{limit}` and `{offset}`)
+// are always bound as parameters and never interpolated. `{like}` is the
+// keyword as a LIKE pattern escaped by backslashes. MySQL and PostgreSQL use
+// backslash as the default LIKE escape character, the others don't have a
+// default one so `ESCAPE '\'` is appended to the parameter for them.
+func (provider *Provider) statement(keyword string, limit, offset int) (string, []interface{}) {
+	t := provider.template
+	if !limitRe.MatchString(t) {
+		t += " LIMIT {limit} OFFSET {offset}"
+	}
+
+	var qa []interface{}
+	q := placeholderRe.ReplaceAllStringFunc(t, func(s string) string {
+		switch s {
+		case "{keyword}":
+			qa = append(qa, keyword)
+		case "{like}":
+			qa = append(qa, "%"+strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(keyword)+"%")
+			if provider.driver != "mysql" && provider.driver != "postgres" && provider.driver != "pgx" {
+				return placeholder(provider.driver, len(qa)) + ` ESCAPE '\'`
+			}
+		case "{limit}":
+			qa = append(qa, limit)
+		case "{offset}":
+			qa = append(qa, offset)
+		}
+		return placeholder(provider.driver, len(qa))
+	})
+
+	return q, qa
+}
+
+// placeholder returns the nth parameter placeholder of the given driver
+func placeholder(driver string, n int) string {
+	switch driver {
+	case "postgres", "pgx":
+		return fmt.Sprintf("$%d", n)
+	case "sqlserver", "mssql":
+		return fmt.Sprintf("@p%d", n)
+	default:
+		return "?"
+	}
+}
+
+// toString converts the given column value to string
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	default:
+		return fmt.Sprintf("%v", s)
+	}
+}
+
+// toTime converts the given column value to time
+func toTime(v interface{}) time.Time {
+	switch t := v.(type) {
+	case time.Time:
+		return t
+	case int64:
+		return time.Unix(t, 0)
+	case string, []byte:
+		s := toString(t)
+		for _, l := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+			if ts, err := time.Parse(l, s); err == nil {
+				return ts
+			}
+		}
+	}
+	return time.Time{}
+}
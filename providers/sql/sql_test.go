@@ -0,0 +1,63 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package sql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStatement(t *testing.T) {
+	tests := []struct {
+		driver   string
+		template string
+		query    string
+		args     []interface{}
+	}{
+		{
+			"postgres",
+			"SELECT * FROM t WHERE name ILIKE {like} OR id = {keyword}",
+			"SELECT * FROM t WHERE name ILIKE $1 OR id = $2 LIMIT $3 OFFSET $4",
+			[]interface{}{`%a\%b\_c\\%`, `a%b_c\`, 10, 20},
+		},
+		{
+			"mysql",
+			"SELECT * FROM t WHERE name LIKE {like} LIMIT {offset}, {limit}",
+			"SELECT * FROM t WHERE name LIKE ? LIMIT ?, ?",
+			[]interface{}{`%a\%b\_c\\%`, 20, 10},
+		},
+		{
+			"sqlite3",
+			"SELECT * FROM t WHERE name LIKE {like}",
+			`SELECT * FROM t WHERE name LIKE ? ESCAPE '\' LIMIT ? OFFSET ?`,
+			[]interface{}{`%a\%b\_c\\%`, 10, 20},
+		},
+	}
+	for _, tt := range tests {
+		p := Provider{driver: tt.driver, template: tt.template}
+		q, qa := p.statement(`a%b_c\`, 10, 20)
+		if q != tt.query {
+			t.Errorf("%s: expected query %s, got %s", tt.driver, tt.query, q)
+		}
+		if !reflect.DeepEqual(qa, tt.args) {
+			t.Errorf("%s: expected args %v, got %v", tt.driver, tt.args, qa)
+		}
+	}
+}
+
+func TestRegisterUnlinkedDriver(t *testing.T) {
+	if linked("sqlite3") {
+		t.Skip("sqlite3 driver is linked")
+	}
+	defer func() {
+		r := recover()
+		if r == nil || r.(string) != "sql driver sqlite3 is not linked. Build Ferret with the `sqlite3` build tag (i.e. go build -tags sqlite3)" {
+			t.Errorf("unexpected panic %v", r)
+		}
+	}()
+	Register(map[string]interface{}{"Driver": "sqlite3", "DSN": "file::memory:", "Template": "SELECT * FROM t"}, func(interface{}) error { return nil })
+}
@@ -0,0 +1,279 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package stackexchange implements Stack Exchange provider
+//
+// It supports the public Stack Exchange sites (i.e. Stack Overflow) and
+// Stack Overflow for Teams. The total number of the results is reported
+// only when a custom filter which includes the total field is configured.
+package stackexchange
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+var tagRe = regexp.MustCompile(`<[^>]*>`)
+
+// Register registers the provider
+func Register(config map[string]interface{}, f func(interface{}) error) {
+
+	name, ok := config["Name"].(string)
+	if name == "" || !ok {
+		name = "stackexchange"
+	}
+	title, ok := config["Title"].(string)
+	if title == "" || !ok {
+		title = "Stack Exchange"
+	}
+	priority, ok := config["Priority"].(int64)
+	if priority == 0 || !ok {
+		priority = 1000
+	}
+	site, _ := config["Site"].(string)
+	team, _ := config["Team"].(string)
+	u, _ := config["URL"].(string)
+	if u == "" {
+		u = "https://api.stackexchange.com/2.3"
+		if team != "" {
+			u = "https://api.stackoverflowteams.com/2.3"
+		}
+	}
+	key, _ := config["Key"].(string)
+	token, _ := config["Token"].(string)
+	query, _ := config["Query"].(string)
+	rewrite, _ := config["Rewrite"].(string)
+	models, _ := config["Models"].([]string)
+	if len(models) == 0 {
+		models = []string{"question", "answer"}
+	}
+	topics, _ := config["Topics"].([]string)
+	filter, _ := config["Filter"].(string)
+
+	p := Provider{
+		provider: "stackexchange",
+		name:     name,
+		title:    title,
+		priority: priority,
+		url:      strings.TrimSuffix(u, "/"),
+		site:     site,
+		team:     team,
+		key:      key,
+		token:    token,
+		query:    query,
+		rewrite:  rewrite,
+		models:   models,
+		tags:     topics,
+		filter:   filter,
+	}
+
+	// The public sites don't require any credential so the provider is
+	// enabled only if it's configured explicitly
+	if site != "" || team != "" || key != "" || token != "" {
+		p.enabled = true
+	}
+	if p.site == "" {
+		p.site = "stackoverflow"
+	}
+	if err := f(&p); err != nil {
+		panic(err)
+	}
+}
+
+// Provider represents the provider
+type Provider struct {
+	provider string
+	enabled  bool
+	name     string
+	title    string
+	priority int64
+	url      string
+	site     string
+	team     string
+	key      string
+	token    string
+	query    string
+	rewrite  string
+	models   []string
+	tags     []string
+	filter   string
+}
+
+// Search makes a search
+func (provider *Provider) Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error) {
+
+	results := []map[string]interface{}{}
+	page, ok := args["page"].(int)
+	if page < 1 || !ok {
+		page = 1
+	}
+	limit, ok := args["limit"].(int)
+	if limit < 1 || !ok {
+		limit = 10
+	}
+	keyword, ok := args["keyword"].(string)
+
+	u := fmt.Sprintf("%s/search/excerpts?order=desc&sort=relevance&site=%s&page=%d&pagesize=%d&q=%s", provider.url, url.QueryEscape(provider.site), page, limit, url.QueryEscape(keyword))
+	if provider.team != "" {
+		u += "&team=" + url.QueryEscape(provider.team)
+	}
+	if len(provider.tags) > 0 {
+		u += "&tagged=" + url.QueryEscape(strings.Join(provider.tags, ";"))
+	}
+	if provider.filter != "" {
+		u += "&filter=" + url.QueryEscape(provider.filter)
+	}
+	if provider.key != "" {
+		u += "&key=" + url.QueryEscape(provider.key)
+	}
+	if provider.token != "" && provider.team == "" {
+		u += "&access_token=" + url.QueryEscape(provider.token)
+	}
+	if provider.query != "" {
+		u += fmt.Sprintf("%s", provider.query)
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, errors.New("failed to prepare request. Error: " + err.Error())
+	}
+	if provider.token != "" && provider.team != "" {
+		req.Header.Set("X-API-Access-Token", provider.token)
+	}
+
+	res, err := ctxhttp.Do(ctx, nil, req)
+	if err != nil {
+		return nil, err
+	} else if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+		var er SearchResult
+		if data, err := ioutil.ReadAll(res.Body); err == nil && json.Unmarshal(data, &er) == nil && er.ErrorMessage != "" {
+			return nil, errors.New("bad response: " + fmt.Sprintf("%d %s", res.StatusCode, er.ErrorMessage))
+		}
+		return nil, errors.New("bad response: " + fmt.Sprintf("%d", res.StatusCode))
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	var sr SearchResult
+	if err := json.Unmarshal(data, &sr); err != nil {
+		return nil, errors.New("failed to unmarshal JSON data. Error: " + err.Error())
+	}
+	// The default filter doesn't include the total field so it's reported
+	// only when a custom filter is configured
+	if provider.filter != "" && sr.Total > 0 {
+		args["total"] = sr.Total
+	}
+
+	for _, v := range sr.Items {
+		if !provider.hasModel(v.ItemType) {
+			continue
+		}
+
+		var l, tt, d string
+		title := html.UnescapeString(v.Title)
+		switch v.ItemType {
+		case "answer":
+			l = fmt.Sprintf("%s/a/%d", provider.siteURL(), v.AnswerID)
+			if v.IsAccepted {
+				tt = "Accepted answer to " + title
+			} else {
+				tt = "Answer to " + title
+			}
+			d = fmt.Sprintf("(%d votes)", v.Score)
+		default:
+			l = fmt.Sprintf("%s/questions/%d", provider.siteURL(), v.QuestionID)
+			tt = title
+			if v.HasAcceptedAnswer {
+				tt += " [accepted]"
+			}
+			d = fmt.Sprintf("(%d votes, %d answers)", v.Score, v.AnswerCount)
+		}
+		for _, tag := range v.Tags {
+			d += " [" + tag + "]"
+		}
+		if e := text(v.Excerpt); e != "" {
+			d += " " + e
+		}
+		if len(d) > 255 {
+			d = d[0:252] + "..."
+		}
+
+		ri := map[string]interface{}{
+			"Link":        l,
+			"Title":       tt,
+			"Description": d,
+			"Date":        time.Unix(v.CreationDate, 0),
+		}
+		results = append(results, ri)
+	}
+
+	return results, nil
+}
+
+// siteURL returns the URL of the site for the result links
+func (provider *Provider) siteURL() string {
+	switch {
+	case provider.team != "":
+		return "https://stackoverflowteams.com/c/" + provider.team
+	case strings.Contains(provider.site, "."):
+		return "https://" + provider.site
+	case provider.site == "stackoverflow", provider.site == "serverfault", provider.site == "superuser", provider.site == "askubuntu":
+		return "https://" + provider.site + ".com"
+	}
+	return "https://" + provider.site + ".stackexchange.com"
+}
+
+// hasModel checks whether the given item type is enabled or not
+func (provider *Provider) hasModel(model string) bool {
+	for _, v := range provider.models {
+		if v == model {
+			return true
+		}
+	}
+	return false
+}
+
+// text converts the given HTML excerpt to plain text
+func text(s string) string {
+	s = html.UnescapeString(tagRe.ReplaceAllString(s, ""))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// SearchResult represents the structure of the search result
+type SearchResult struct {
+	Items        []*SRItems `json:"items"`
+	HasMore      bool       `json:"has_more"`
+	Total        int        `json:"total"`
+	ErrorMessage string     `json:"error_message"`
+}
+
+// SRItems represents the structure of the search result items
+type SRItems struct {
+	ItemType          string   `json:"item_type"`
+	QuestionID        int      `json:"question_id"`
+	AnswerID          int      `json:"answer_id"`
+	Title             string   `json:"title"`
+	Excerpt           string   `json:"excerpt"`
+	Score             int      `json:"score"`
+	Tags              []string `json:"tags"`
+	AnswerCount       int      `json:"answer_count"`
+	IsAccepted        bool     `json:"is_accepted"`
+	HasAcceptedAnswer bool     `json:"has_accepted_answer"`
+	CreationDate      int64    `json:"creation_date"`
+}
@@ -0,0 +1,112 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+// Package text implements the text helpers of the providers
+package text
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Snippet returns a part of the given text which contains the keyword.
+// The text is kept as is if it's short enough, otherwise some context
+// before the first match is kept and the rest is trimmed to 255 bytes.
+func Snippet(text, keyword string) string {
+	if len(text) <= 255 {
+		return text
+	}
+
+	// Find the first match of the keyword or one of its words.
+	// The matching is made on the text itself since the case mappings
+	// may change the byte lengths (i.e. Ⱥ and ⱥ) and so the offsets.
+	p := -1
+	if keyword = strings.TrimSpace(keyword); keyword != "" {
+		p = indexFold(text, keyword)
+		if p < 0 {
+			for _, w := range strings.Fields(keyword) {
+				if i := indexFold(text, w); i >= 0 && (p < 0 || i < p) {
+					p = i
+				}
+			}
+		}
+	}
+
+	return SnippetAt(text, p)
+}
+
+// SnippetAt returns a part of the given text which contains the given byte offset
+// (i.e. the offset of a match). The text is kept as is if it's short enough,
+// otherwise some context before the offset is kept and the rest is trimmed to 255 bytes.
+func SnippetAt(text string, p int) string {
+	if len(text) <= 255 {
+		return text
+	}
+
+	// Keep some context before the match
+	if p > len(text) {
+		p = len(text)
+	}
+	if p > 60 {
+		s := p - 60
+		if i := strings.Index(text[s:p], " "); i >= 0 {
+			s += i + 1
+		}
+		for s < len(text) && !utf8.RuneStart(text[s]) {
+			s++
+		}
+		text = "..." + text[s:]
+	}
+	if len(text) > 255 {
+		e := 252
+		for !utf8.RuneStart(text[e]) {
+			e--
+		}
+		text = text[0:e] + "..."
+	}
+	return text
+}
+
+// indexFold returns the byte offset of the first case-insensitive match of
+// substr in s or -1 if there is no match
+func indexFold(s, substr string) int {
+	for i := range s {
+		if hasPrefixFold(s[i:], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// hasPrefixFold checks whether s begins with prefix case-insensitively
+func hasPrefixFold(s, prefix string) bool {
+	for prefix != "" {
+		if s == "" {
+			return false
+		}
+		r1, n1 := utf8.DecodeRuneInString(s)
+		r2, n2 := utf8.DecodeRuneInString(prefix)
+		if !equalFold(r1, r2) {
+			return false
+		}
+		s, prefix = s[n1:], prefix[n2:]
+	}
+	return true
+}
+
+// equalFold checks whether the given runes are equal under the simple case folding
+func equalFold(r1, r2 rune) bool {
+	if r1 == r2 {
+		return true
+	}
+	for r := unicode.SimpleFold(r1); r != r1; r = unicode.SimpleFold(r) {
+		if r == r2 {
+			return true
+		}
+	}
+	return false
+}
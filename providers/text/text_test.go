@@ -0,0 +1,80 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package text
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		keyword string
+		prefix  string
+	}{
+		// Ⱥ (2 bytes) is lowercased to ⱥ (3 bytes)
+		{"longer lowercase", strings.Repeat("Ⱥ", 200) + " needle", "needle", "..."},
+		// K (Kelvin sign, 3 bytes) is lowercased to k (1 byte)
+		{"shorter lowercase", strings.Repeat("\u212a", 200) + " needle", "NEEDLE", "..."},
+		// İ (2 bytes) is lowercased to i̇ (3 bytes)
+		{"dotted capital", strings.Repeat("İ", 150) + " " + strings.Repeat("x", 100) + " needle " + strings.Repeat("y", 100), "Needle", "..."},
+		{"case insensitive", strings.Repeat("a ", 100) + "Needle " + strings.Repeat("b ", 100), "nEEDLE", "..."},
+		{"word match", strings.Repeat("a ", 100) + "the needle " + strings.Repeat("b ", 100), "haystack needle", "..."},
+		{"no match", strings.Repeat("Ⱥ", 200), "needle", "ȺȺ"},
+		{"short", "Ⱥ needle", "needle", "Ⱥ needle"},
+	}
+	for _, tt := range tests {
+		s := Snippet(tt.text, tt.keyword)
+		if !utf8.ValidString(s) {
+			t.Errorf("%s: invalid UTF-8 %q", tt.name, s)
+		}
+		if len(s) > 255 {
+			t.Errorf("%s: expected at most 255 bytes, got %d", tt.name, len(s))
+		}
+		if !strings.HasPrefix(s, tt.prefix) {
+			t.Errorf("%s: expected prefix %q, got %q", tt.name, tt.prefix, s)
+		}
+		if tt.prefix == "..." && !strings.Contains(strings.ToLower(s), "needle") {
+			t.Errorf("%s: expected the match in %q", tt.name, s)
+		}
+	}
+}
+
+func TestSnippetAt(t *testing.T) {
+	text := strings.Repeat("Ⱥ", 100) + " match " + strings.Repeat("Ⱥ", 100)
+	for _, p := range []int{0, 61, 199, 201, 203, len(text), len(text) + 10} {
+		s := SnippetAt(text, p)
+		if !utf8.ValidString(s) || len(s) > 255 {
+			t.Errorf("SnippetAt(%d): invalid snippet %q", p, s)
+		}
+	}
+	if s := SnippetAt(text, 201); !strings.HasPrefix(s, "...match") {
+		t.Errorf("SnippetAt: expected the context to start at the word boundary, got %q", s)
+	}
+}
+
+func TestIndexFold(t *testing.T) {
+	tests := []struct {
+		s, substr string
+		index     int
+	}{
+		{"Hello World", "world", 6},
+		{"ȺȺ needle", "NEEDLE", 5},
+		{"ȺȺ ⱥ", "Ⱥ", 0},
+		{"\u212aKk", "kk", 0},
+		{"abc", "d", -1},
+		{"ab", "abc", -1},
+	}
+	for _, tt := range tests {
+		if i := indexFold(tt.s, tt.substr); i != tt.index {
+			t.Errorf("indexFold(%q, %q): expected %d, got %d", tt.s, tt.substr, tt.index, i)
+		}
+	}
+}
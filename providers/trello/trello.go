@@ -40,17 +40,32 @@ func Register(config map[string]interface{}, f func(interface{}) error) {
 	token, _ := config["Token"].(string)
 	query, _ := config["Query"].(string)
 	rewrite, _ := config["Rewrite"].(string)
+	u, _ := config["URL"].(string)
+	if u == "" {
+		u = "https://api.trello.com/1"
+	}
+	models, _ := config["Models"].([]string)
+	if len(models) == 0 {
+		models = []string{"cards"}
+	}
+	boards, _ := config["Boards"].([]string)
+	organization, _ := config["Organization"].(string)
+	archived, _ := config["Archived"].(bool)
 
 	p := Provider{
-		provider: "trello",
-		name:     name,
-		title:    title,
-		priority: priority,
-		url:      "https://api.trello.com/1",
-		key:      key,
-		token:    token,
-		query:    query,
-		rewrite:  rewrite,
+		provider:     "trello",
+		name:         name,
+		title:        title,
+		priority:     priority,
+		url:          strings.TrimSuffix(u, "/"),
+		key:          key,
+		token:        token,
+		query:        query,
+		rewrite:      rewrite,
+		models:       models,
+		boards:       boards,
+		organization: organization,
+		archived:     archived,
 	}
 	if p.token != "" {
 		p.enabled = true
@@ -63,16 +78,20 @@ func Register(config map[string]interface{}, f func(interface{}) error) {
 
 // Provider represents the provider
 type Provider struct {
-	provider string
-	enabled  bool
-	name     string
-	title    string
-	priority int64
-	url      string
-	key      string
-	token    string
-	query    string
-	rewrite  string
+	provider     string
+	enabled      bool
+	name         string
+	title        string
+	priority     int64
+	url          string
+	key          string
+	token        string
+	query        string
+	rewrite      string
+	models       []string
+	boards       []string
+	organization string
+	archived     bool
 }
 
 // Search makes a search
@@ -89,7 +108,27 @@ func (provider *Provider) Search(ctx context.Context, args map[string]interface{
 	}
 	keyword, ok := args["keyword"].(string)
 
-	u := fmt.Sprintf("%s/search?key=%s&token=%s&partial=true&modelTypes=cards&card_fields=name,shortUrl,desc,dateLastActivity&cards_page=%d&cards_limit=%d&query=%s", provider.url, provider.key, provider.token, (page - 1), limit, url.QueryEscape(keyword))
+	// Trello pages the models separately so the first page*limit results of
+	// each model are fetched and the page is sliced from the merged results.
+	// Trello returns up to 1000 results per model.
+	n := page * limit
+	if n > 1000 {
+		n = 1000
+	}
+	if !provider.archived {
+		keyword += " is:open"
+	}
+
+	u := fmt.Sprintf("%s/search?key=%s&token=%s&partial=true&modelTypes=%s&query=%s", provider.url, provider.key, provider.token, strings.Join(provider.models, ","), url.QueryEscape(keyword))
+	u += fmt.Sprintf("&card_fields=name,shortUrl,desc,dateLastActivity,closed,labels,badges&card_board=true&card_list=true&cards_page=0&cards_limit=%d", n)
+	u += fmt.Sprintf("&board_fields=name,shortUrl,desc,dateLastActivity,closed&boards_limit=%d", n)
+	u += fmt.Sprintf("&member_fields=fullName,username,url,bio&members_limit=%d", n)
+	if len(provider.boards) > 0 {
+		u += fmt.Sprintf("&idBoards=%s", url.QueryEscape(strings.Join(provider.boards, ",")))
+	}
+	if provider.organization != "" {
+		u += fmt.Sprintf("&idOrganizations=%s", url.QueryEscape(provider.organization))
+	}
 	if provider.query != "" {
 		u += fmt.Sprintf("%s", provider.query)
 	}
@@ -113,39 +152,160 @@ func (provider *Provider) Search(ctx context.Context, args map[string]interface{
 	if err := json.Unmarshal(data, &sr); err != nil {
 		return nil, errors.New("failed to unmarshal JSON data. Error: " + err.Error())
 	}
+	for _, v := range sr.Boards {
+		ri := map[string]interface{}{
+			"Link":        v.URL,
+			"Title":       fmt.Sprintf("Board: %s", v.Name),
+			"Description": trimDescription(v.Description),
+			"Date":        parseDate(v.DateLastActivity),
+		}
+		results = append(results, ri)
+	}
+	for _, v := range sr.Members {
+		d := fmt.Sprintf("@%s", v.Username)
+		if b := strings.TrimSpace(v.Bio); b != "" {
+			d += " - " + b
+		}
+		ri := map[string]interface{}{
+			"Link":        v.URL,
+			"Title":       fmt.Sprintf("Member: %s", v.Fullname),
+			"Description": trimDescription(d),
+		}
+		results = append(results, ri)
+	}
 	for _, v := range sr.Cards {
-		d := strings.TrimSpace(v.Description)
-		if len(d) > 255 {
-			d = d[0:252] + "..."
+
+		// Board and list context
+		tt := v.Name
+		if v.Board != nil && v.List != nil {
+			tt = fmt.Sprintf("%s in %s / %s", v.Name, v.Board.Name, v.List.Name)
+		} else if v.Board != nil {
+			tt = fmt.Sprintf("%s in %s", v.Name, v.Board.Name)
 		}
 
-		var t time.Time
-		if ts, err := time.Parse("2006-01-02T15:04:05.000Z", v.DateLastActivity); err == nil {
-			t = ts
+		// Labels and checklists
+		var cl []string
+		if len(v.Labels) > 0 {
+			ll := []string{}
+			for _, l := range v.Labels {
+				if l.Name != "" {
+					ll = append(ll, l.Name)
+				} else if l.Color != "" {
+					ll = append(ll, l.Color)
+				}
+			}
+			if len(ll) > 0 {
+				cl = append(cl, "["+strings.Join(ll, ", ")+"]")
+			}
+		}
+		if v.Badges != nil && v.Badges.CheckItems > 0 {
+			cl = append(cl, fmt.Sprintf("(%d/%d checked)", v.Badges.CheckItemsChecked, v.Badges.CheckItems))
+		}
+		d := strings.TrimSpace(v.Description)
+		if len(cl) > 0 {
+			d = strings.TrimSpace(strings.Join(cl, " ") + " " + d)
 		}
 
 		ri := map[string]interface{}{
 			"Link":        v.URL,
-			"Title":       v.Name,
-			"Description": d,
-			"Date":        t,
+			"Title":       tt,
+			"Description": trimDescription(d),
+			"Date":        parseDate(v.DateLastActivity),
 		}
 		results = append(results, ri)
 	}
 
+	// Page
+	l, h := (page-1)*limit, page*limit
+	if l > len(results) {
+		l = len(results)
+	}
+	if h > len(results) {
+		h = len(results)
+	}
+	results = results[l:h]
+
 	return results, err
 }
 
+// trimDescription trims the given description for the search results
+func trimDescription(d string) string {
+	d = strings.TrimSpace(d)
+	if len(d) > 255 {
+		d = d[0:252] + "..."
+	}
+	return d
+}
+
+// parseDate parses the given Trello date
+func parseDate(d string) time.Time {
+	var t time.Time
+	if ts, err := time.Parse("2006-01-02T15:04:05.000Z", d); err == nil {
+		t = ts
+	}
+	return t
+}
+
 // SearchResult represents the structure of the search result
 type SearchResult struct {
-	Cards []*SRCards `json:"cards"`
+	Cards   []*SRCards   `json:"cards"`
+	Boards  []*SRBoards  `json:"boards"`
+	Members []*SRMembers `json:"members"`
 }
 
 // SRCards represents the structure of the search result list
 type SRCards struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	URL              string       `json:"shortUrl"`
+	Description      string       `json:"desc"`
+	DateLastActivity string       `json:"dateLastActivity"`
+	Closed           bool         `json:"closed"`
+	Labels           []*SRCLabels `json:"labels"`
+	Badges           *SRCBadges   `json:"badges"`
+	Board            *SRCBoard    `json:"board"`
+	List             *SRCList     `json:"list"`
+}
+
+// SRCLabels represents the structure of the search result cards labels field
+type SRCLabels struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// SRCBadges represents the structure of the search result cards badges field
+type SRCBadges struct {
+	CheckItems        int `json:"checkItems"`
+	CheckItemsChecked int `json:"checkItemsChecked"`
+}
+
+// SRCBoard represents the structure of the search result cards board field
+type SRCBoard struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// SRCList represents the structure of the search result cards list field
+type SRCList struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// SRBoards represents the structure of the search result boards
+type SRBoards struct {
 	ID               string `json:"id"`
 	Name             string `json:"name"`
 	URL              string `json:"shortUrl"`
 	Description      string `json:"desc"`
 	DateLastActivity string `json:"dateLastActivity"`
+	Closed           bool   `json:"closed"`
+}
+
+// SRMembers represents the structure of the search result members
+type SRMembers struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Fullname string `json:"fullName"`
+	URL      string `json:"url"`
+	Bio      string `json:"bio"`
 }
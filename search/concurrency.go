@@ -0,0 +1,85 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package search
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+var (
+	semaphores   = make(map[string]chan struct{})
+	semaphoresMu sync.Mutex
+)
+
+// concurrency returns the maximum concurrent searches of the given provider.
+// The provider concurrency overrides the search concurrency.
+func concurrency(provider Provider) int64 {
+	if provider.Concurrency > 0 {
+		return provider.Concurrency
+	}
+	return config.Concurrency
+}
+
+// Limited checks whether the concurrent searches of any provider are limited or not
+func Limited() bool {
+	if config.Concurrency > 0 {
+		return true
+	}
+	for _, v := range providers {
+		if v.Concurrency > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire acquires a search slot of the given provider. It waits for a free slot
+// until the context is done when the concurrent searches reach the limit.
+func acquire(ctx context.Context, provider Provider) bool {
+	n := concurrency(provider)
+	if n <= 0 {
+		searchInflight.Add(1, provider.Name)
+		return true
+	}
+
+	semaphoresMu.Lock()
+	sem, ok := semaphores[provider.Name]
+	if !ok {
+		sem = make(chan struct{}, n)
+		semaphores[provider.Name] = sem
+	}
+	semaphoresMu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		searchInflight.Add(1, provider.Name)
+		return true
+	default:
+	}
+	searchWaited.Inc(provider.Name)
+	select {
+	case sem <- struct{}{}:
+		searchInflight.Add(1, provider.Name)
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release releases a search slot of the given provider
+func release(provider Provider) {
+	searchInflight.Add(-1, provider.Name)
+	if concurrency(provider) <= 0 {
+		return
+	}
+	semaphoresMu.Lock()
+	sem := semaphores[provider.Name]
+	semaphoresMu.Unlock()
+	<-sem
+}
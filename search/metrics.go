@@ -0,0 +1,40 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package search
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yieldbot/ferret/metrics"
+)
+
+var (
+	searchDuration = metrics.NewHistogram("ferret_search_duration_seconds", "Search latency per provider.", nil, "provider")
+	searchTotal    = metrics.NewCounter("ferret_searches_total", "Searches per provider and HTTP status (504 for the timeouts, 429 for the concurrency limit).", "provider", "status")
+	searchResults  = metrics.NewCounter("ferret_search_results_total", "Search results per provider.", "provider")
+	searchCache    = metrics.NewCounter("ferret_search_cache_total", "Search cache lookups per provider and status (hit or miss).", "provider", "cache")
+	searchInflight = metrics.NewGauge("ferret_searches_in_flight", "In-flight searches per provider.", "provider")
+	searchWaited   = metrics.NewCounter("ferret_search_waits_total", "Searches which waited for a free slot of the concurrency limit per provider.", "provider")
+)
+
+// observe records the metrics of the given provider and query
+func observe(provider string, query *Query, elapsed time.Duration) {
+	status := query.HTTPStatus
+	if status == 0 {
+		status = http.StatusOK
+	}
+	searchDuration.Observe(elapsed.Seconds(), provider)
+	searchTotal.Inc(provider, strconv.Itoa(status))
+	if status == http.StatusOK {
+		searchResults.Add(float64(len(query.Results)), provider)
+	}
+	if query.Cache != "" {
+		searchCache.Inc(provider, query.Cache)
+	}
+}
@@ -0,0 +1,37 @@
+/*
+ * Ferret
+ * Copyright (c) 2016 Yieldbot, Inc.
+ * For the full copyright and license information, please view the LICENSE.txt file.
+ */
+
+package search
+
+import (
+	"errors"
+
+	"golang.org/x/net/context"
+)
+
+// ErrProbeUnsupported is returned by Probe for the providers which don't implement Prober
+var ErrProbeUnsupported = errors.New("probe is not supported")
+
+// Prober is the interface that can be implemented by a search provider
+// for the readiness checks
+type Prober interface {
+	// Probe checks the reachability of the provider by a lightweight request
+	// which is cheaper than a search (i.e. an auth or a rate limit endpoint).
+	Probe(ctx context.Context) error
+}
+
+// Probe probes the given provider
+func Probe(ctx context.Context, name string) error {
+	p, err := ProviderByName(name)
+	if err != nil {
+		return err
+	}
+	pr, ok := p.Searcher.(Prober)
+	if !ok {
+		return ErrProbeUnsupported
+	}
+	return pr.Probe(ctx)
+}
@@ -14,12 +14,15 @@ import (
 
 // Provider represents a provider
 type Provider struct {
-	Name     string
-	Title    string
-	Enabled  bool
-	Noui     bool
-	Priority int64
-	Rewrite  string
+	Name        string
+	Title       string
+	Enabled     bool
+	Noui        bool
+	Priority    int64
+	Rewrite     string
+	Delegated   bool
+	Fallback    bool
+	Concurrency int64
 	Searcher
 }
 
@@ -44,6 +47,11 @@ func ProviderByName(name string) (Provider, error) {
 
 // ProviderRegister registers a search provider
 func ProviderRegister(provider interface{}) error {
+	return providerRegister(provider, 0)
+}
+
+// providerRegister registers a search provider by the given maximum concurrent searches
+func providerRegister(provider interface{}, concurrency int64) error {
 
 	// Init provider
 	p, ok := provider.(Searcher)
@@ -52,7 +60,7 @@ func ProviderRegister(provider interface{}) error {
 	}
 
 	var name, title, rewrite string
-	var enabled, noui bool
+	var enabled, noui, delegated, fallback bool
 	var priority int64
 
 	// Get the value of the provider
@@ -74,6 +82,10 @@ func ProviderRegister(provider interface{}) error {
 			priority = v.Field(i).Int()
 		} else if fn == "rewrite" && ft == "string" {
 			rewrite = v.Field(i).String()
+		} else if fn == "delegated" && ft == "bool" {
+			delegated = v.Field(i).Bool()
+		} else if fn == "fallback" && ft == "bool" {
+			fallback = v.Field(i).Bool()
 		}
 	}
 	if name == "" {
@@ -88,13 +100,16 @@ func ProviderRegister(provider interface{}) error {
 		return errors.New("search provider " + name + " is already registered")
 	}
 	np := Provider{
-		Name:     name,
-		Title:    title,
-		Enabled:  enabled,
-		Noui:     noui,
-		Priority: priority,
-		Rewrite:  rewrite,
-		Searcher: p,
+		Name:        name,
+		Title:       title,
+		Enabled:     enabled,
+		Noui:        noui,
+		Priority:    priority,
+		Rewrite:     rewrite,
+		Delegated:   delegated,
+		Fallback:    fallback,
+		Concurrency: concurrency,
+		Searcher:    p,
 	}
 	providers[name] = np
 
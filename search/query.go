@@ -30,6 +30,11 @@ type Query struct {
 	Timeout    time.Duration
 	Start      time.Time
 	Elapsed    time.Duration
+	Total      int
+	Cache      string
+	Warnings   []string
+	User       string
+	Token      string
 	HTTPStatus int
 	Results    Results
 }
@@ -65,12 +70,32 @@ func (query *Query) Do() error {
 		return errors.New("invalid limit. It should be greater than 0")
 	}
 
+	// Delegated credential
+	// The shared credential is used for the users without a credential only if
+	// the provider falls back to it. The CLI (no user) always uses the shared one
+	// and the API refuses to listen without the auth for the providers which don't fall back.
+	if provider.Delegated && query.User != "" && query.Token == "" && !provider.Fallback {
+		query.HTTPStatus = http.StatusForbidden
+		return fmt.Errorf("missing credential for %s. Link a token for the provider first", provider.Name)
+	}
+
 	// Search
 	query.Start = time.Now()
+	defer func() {
+		observe(provider.Name, query, time.Since(query.Start))
+	}()
 	ctx, cancel := context.WithTimeout(context.Background(), query.Timeout)
 	defer cancel()
 	sq := map[string]interface{}{"page": query.Page, "limit": query.Limit, "keyword": query.Keyword}
+	if provider.Delegated && query.Token != "" {
+		sq["token"] = query.Token
+	}
+	if !acquire(ctx, provider) {
+		query.HTTPStatus = http.StatusTooManyRequests
+		return errors.New("too many concurrent searches for " + provider.Name)
+	}
 	sr, err := provider.Search(ctx, sq)
+	release(provider)
 	if err != nil {
 		if err == context.DeadlineExceeded {
 			query.HTTPStatus = http.StatusGatewayTimeout
@@ -83,6 +108,17 @@ func (query *Query) Do() error {
 		return errors.New("failed to search due to " + err.Error())
 	}
 	query.Elapsed = time.Since(query.Start)
+	// Providers may report the total number of the results, the cache status
+	// and the warnings by the args
+	if t, ok := sq["total"].(int); ok {
+		query.Total = t
+	}
+	if c, ok := sq["cache"].(string); ok {
+		query.Cache = c
+	}
+	if w, ok := sq["warnings"].([]string); ok {
+		query.Warnings = w
+	}
 	for _, srv := range sr {
 		var d string
 		if _, ok := srv["Description"]; ok {
@@ -159,7 +195,11 @@ func (query *Query) DoPrint(err error) {
 			t.AddRow(i+2, fmt.Sprintf("%d", i+1), fmt.Sprintf("%s%s", v.Title, ts))
 		}
 		t.PrintData()
-		fmt.Printf("\n%d rows in %dms\n", len(query.Results), int64(query.Elapsed/time.Millisecond))
+		if query.Total > 0 {
+			fmt.Printf("\n%d of %d rows in %dms\n", len(query.Results), query.Total, int64(query.Elapsed/time.Millisecond))
+		} else {
+			fmt.Printf("\n%d rows in %dms\n", len(query.Results), int64(query.Elapsed/time.Millisecond))
+		}
 	}
 }
 
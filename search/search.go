@@ -37,11 +37,22 @@ func Init(c conf.Config) {
 		}
 		cm = append(cm, m)
 	}
-	prov.Register(cm, ProviderRegister)
+	for _, m := range cm {
+		// The providers are registered one by one for their concurrency
+		c, _ := m["Concurrency"].(int64)
+		prov.Register([]map[string]interface{}{m}, func(p interface{}) error {
+			return providerRegister(p, c)
+		})
+	}
 }
 
 // Searcher is the interface that must be implemented by a search provider
 type Searcher interface {
-	// Search makes a search
+	// Search makes a search.
+	// The total number of the results can be reported by setting args["total"],
+	// the cache status ("hit" or "miss") by args["cache"] and the non-fatal
+	// errors by args["warnings"] ([]string).
+	// Providers which have a `delegated` field receive the credential of the
+	// requesting user by args["token"] and use it instead of the shared one.
 	Search(ctx context.Context, args map[string]interface{}) ([]map[string]interface{}, error)
 }